@@ -0,0 +1,75 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bcspragu/anylist/anylist"
+	"github.com/bcspragu/anylist/pb"
+)
+
+type Account struct {
+	FirstName        string        `json:"first_name"`
+	LastName         string        `json:"last_name"`
+	Email            string        `json:"email"`
+	Premium          bool          `json:"premium"`
+	SubscriptionType int           `json:"subscription_type"`
+	ExpiresAt        *time.Time    `json:"expires_at,omitempty"`
+	MasterUser       *AccountUser  `json:"master_user,omitempty"`
+	Subusers         []AccountUser `json:"subusers"`
+}
+
+type AccountUser struct {
+	UserID string `json:"user_id"`
+	Email  string `json:"email"`
+	Name   string `json:"name,omitempty"`
+}
+
+// handleAccount serves /api/account, which returns the account's details and
+// subscription status.
+func handleAccount(c *anylist.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, http.MethodGet) {
+			return
+		}
+		info, err := c.AccountInfo(r.Context())
+		if err != nil {
+			log.Printf("failed to load account info: %v", err)
+			writeError(w, http.StatusBadGateway, "failed to load account info")
+			return
+		}
+		writeJSON(w, http.StatusOK, toAccount(info))
+	}
+}
+
+func toAccount(in *pb.PBAccountInfoResponse) Account {
+	out := Account{
+		FirstName:        in.FirstName,
+		LastName:         in.LastName,
+		Email:            in.Email,
+		Premium:          in.IsPremiumUser,
+		SubscriptionType: int(in.SubscriptionType),
+		Subusers:         []AccountUser{},
+	}
+	if in.ExpirationTimestampMs > 0 {
+		t := time.UnixMilli(in.ExpirationTimestampMs).UTC()
+		out.ExpiresAt = &t
+	}
+	if in.MasterUser != nil {
+		u := toAccountUser(in.MasterUser)
+		out.MasterUser = &u
+	}
+	for _, u := range in.Subusers {
+		out.Subusers = append(out.Subusers, toAccountUser(u))
+	}
+	return out
+}
+
+func toAccountUser(in *pb.PBEmailUserIDPair) AccountUser {
+	return AccountUser{
+		UserID: in.UserId,
+		Email:  in.Email,
+		Name:   in.FullName,
+	}
+}
@@ -0,0 +1,84 @@
+package anylist
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/bcspragu/anylist/pb"
+	"golang.org/x/net/context/ctxhttp"
+	"google.golang.org/protobuf/proto"
+)
+
+// PremiumRequiredError is returned by operations that need an AnyList
+// Complete subscription when the user doesn't have one. The operation isn't
+// sent to AnyList.
+type PremiumRequiredError struct {
+	// Feature describes what the user was trying to do, e.g. "meal planning".
+	Feature string
+}
+
+func (e *PremiumRequiredError) Error() string {
+	return fmt.Sprintf("%s requires an AnyList Complete subscription", e.Feature)
+}
+
+// AccountInfo loads the user's account details, including their subscription
+// status.
+func (c *Client) AccountInfo(ctx context.Context) (*pb.PBAccountInfoResponse, error) {
+	resp, err := ctxhttp.Post(ctx, c.client, "https://www.anylist.com/data/account/info", "application/x-www-form-urlencoded", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load account info: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("invalid response code %d, expected 200 OK", resp.StatusCode)
+	}
+
+	dat, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	m := &pb.PBAccountInfoResponse{}
+	if err := proto.Unmarshal(dat, m); err != nil {
+		return nil, fmt.Errorf("failed to decode proto message: %w", err)
+	}
+
+	c.accountMu.Lock()
+	c.account = m
+	c.accountMu.Unlock()
+
+	return m, nil
+}
+
+// IsPremium reports whether the user has an AnyList Complete subscription.
+// The account info is only loaded the first time, so subscription changes
+// aren't noticed until AccountInfo is called again.
+func (c *Client) IsPremium(ctx context.Context) (bool, error) {
+	c.accountMu.Lock()
+	account := c.account
+	c.accountMu.Unlock()
+
+	if account == nil {
+		var err error
+		if account, err = c.AccountInfo(ctx); err != nil {
+			return false, err
+		}
+	}
+	return account.IsPremiumUser, nil
+}
+
+// requirePremium returns a *PremiumRequiredError if the user doesn't have a
+// subscription.
+func (c *Client) requirePremium(ctx context.Context, feature string) error {
+	premium, err := c.IsPremium(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check subscription status: %w", err)
+	}
+	if !premium {
+		return &PremiumRequiredError{Feature: feature}
+	}
+	return nil
+}
@@ -13,6 +13,7 @@ import (
 	"net/http/cookiejar"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/bcspragu/anylist/pb"
@@ -37,6 +38,10 @@ type Client struct {
 	// Initialized on login
 	signedUserID string
 	userID       string
+
+	// Loaded lazily by AccountInfo, used to check for premium features.
+	accountMu sync.Mutex
+	account   *pb.PBAccountInfoResponse
 }
 
 func FromRefreshToken(ctx context.Context, rTkn string) (*Client, error) {
@@ -216,120 +221,68 @@ func (c *Client) Lists(ctx context.Context) (*pb.PBUserDataResponse, error) {
 }
 
 func (c *Client) AddItem(ctx context.Context, listID string, itemName string) error {
-	itemID := uuid.NewString()
-	req := &pb.PBListOperationList{
-		Operations: []*pb.PBListOperation{
-			{
-				Metadata: &pb.PBOperationMetadata{
-					OperationId: uuid.NewString(),
-					HandlerId:   "add-shopping-list-item",
-					UserId:      c.userID,
-				},
-				ListId:     listID,
-				ListItemId: itemID,
-				ListItem: &pb.ListItem{
-					Identifier:      itemID,
-					ListId:          listID,
-					Name:            itemName,
-					Checked:         false,
-					CategoryMatchId: "other",
-					UserId:          c.userID,
-				},
-			},
-		},
-	}
+	_, err := c.AddListItem(ctx, listID, &pb.ListItem{Name: itemName})
+	return err
+}
 
-	dat, err := proto.Marshal(req)
-	if err != nil {
-		return fmt.Errorf("failed to marshal request message: %w", err)
+func (c *Client) RemoveItem(ctx context.Context, listID, itemID string) error {
+	op := c.RemoveItemOperation(listID, &pb.ListItem{Identifier: itemID})
+	if err := c.SendListOperations(ctx, op); err != nil {
+		return fmt.Errorf("failed to remove item: %w", err)
 	}
-	data := url.Values{}
-	data.Set("operations", string(dat))
+	return nil
+}
 
-	resp, err := ctxhttp.PostForm(ctx, c.client, "https://www.anylist.com/data/shopping-lists/update", data)
-	if err != nil {
-		return fmt.Errorf("failed to add item: %w", err)
-	}
+func (c *Client) SetChecked(ctx context.Context, listID, itemID string, checked bool) error {
+	return c.UpdateItem(ctx, listID, itemID, ItemUpdate{Checked: &checked})
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("invalid response code %d, expected 200 OK", resp.StatusCode)
+// metadata returns the metadata for a new operation handled by the given
+// handler.
+func (c *Client) metadata(handlerID string) *pb.PBOperationMetadata {
+	return &pb.PBOperationMetadata{
+		OperationId: uuid.NewString(),
+		HandlerId:   handlerID,
+		UserId:      c.userID,
 	}
-
-	return nil
 }
 
-func (c *Client) RemoveItem(ctx context.Context, listID, itemID string) error {
-	req := &pb.PBListOperationList{
-		Operations: []*pb.PBListOperation{
-			{
-				Metadata: &pb.PBOperationMetadata{
-					OperationId: uuid.NewString(),
-					HandlerId:   "remove-shopping-list-item",
-					UserId:      c.userID,
-				},
-				ListId:     listID,
-				ListItemId: itemID,
-				ListItem: &pb.ListItem{
-					Identifier: itemID,
-					ListId:     listID,
-				},
-			},
-		},
-	}
+// postOperations sends a batch of operations (e.g. a PBListOperationList) to
+// the given AnyList data endpoint.
+func (c *Client) postOperations(ctx context.Context, path string, ops proto.Message) error {
+	return c.postOperationsWithResponse(ctx, path, ops, nil)
+}
 
-	dat, err := proto.Marshal(req)
+// postOperationsWithResponse is like postOperations, but also decodes the
+// response body into out, if it isn't nil.
+func (c *Client) postOperationsWithResponse(ctx context.Context, path string, ops, out proto.Message) error {
+	dat, err := proto.Marshal(ops)
 	if err != nil {
 		return fmt.Errorf("failed to marshal request message: %w", err)
 	}
 	data := url.Values{}
 	data.Set("operations", string(dat))
 
-	resp, err := ctxhttp.PostForm(ctx, c.client, "https://www.anylist.com/data/shopping-lists/update", data)
+	resp, err := ctxhttp.PostForm(ctx, c.client, "https://www.anylist.com"+path, data)
 	if err != nil {
-		return fmt.Errorf("failed to remove item: %w", err)
+		return fmt.Errorf("failed to send operations: %w", err)
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("invalid response code %d, expected 200 OK", resp.StatusCode)
 	}
 
-	return nil
-}
-
-func (c *Client) SetChecked(ctx context.Context, listID, itemID string, checked bool) error {
-	updatedValue := "y"
-	if !checked {
-		updatedValue = "n"
-	}
-	req := &pb.PBListOperationList{
-		Operations: []*pb.PBListOperation{
-			{
-				Metadata: &pb.PBOperationMetadata{
-					OperationId: uuid.NewString(),
-					HandlerId:   "set-list-item-checked",
-					UserId:      c.userID,
-				},
-				ListId:       listID,
-				ListItemId:   itemID,
-				UpdatedValue: updatedValue,
-			},
-		},
-	}
-
-	dat, err := proto.Marshal(req)
-	if err != nil {
-		return fmt.Errorf("failed to marshal request message: %w", err)
+	if out == nil {
+		return nil
 	}
-	data := url.Values{}
-	data.Set("operations", string(dat))
 
-	resp, err := ctxhttp.PostForm(ctx, c.client, "https://www.anylist.com/data/shopping-lists/update", data)
+	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("failed to updated item checked: %w", err)
+		return fmt.Errorf("failed to read response body: %w", err)
 	}
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("invalid response code %d, expected 200 OK", resp.StatusCode)
+	if err := proto.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to decode response message: %w", err)
 	}
 
 	return nil
@@ -0,0 +1,239 @@
+package anylist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bcspragu/anylist/pb"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+)
+
+const listUpdatePath = "/data/shopping-lists/update"
+
+// ErrNotProcessed is returned by SendListOperations when AnyList didn't apply
+// every operation, e.g. because the list was changed in the meantime.
+var ErrNotProcessed = errors.New("operations weren't processed")
+
+// AddListItem adds an item to a list. Only the item's name is required, an
+// identifier is generated if it doesn't have one. The added item is returned.
+func (c *Client) AddListItem(ctx context.Context, listID string, item *pb.ListItem) (*pb.ListItem, error) {
+	op, err := c.AddItemOperation(listID, item)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.SendListOperations(ctx, op); err != nil {
+		return nil, fmt.Errorf("failed to add item: %w", err)
+	}
+	return op.ListItem, nil
+}
+
+// AddItemOperation returns the operation that adds an item to a list, for use
+// with SendListOperations. See AddListItem.
+func (c *Client) AddItemOperation(listID string, item *pb.ListItem) (*pb.PBListOperation, error) {
+	if item.Name == "" {
+		return nil, errors.New("item must have a name")
+	}
+	item = proto.Clone(item).(*pb.ListItem)
+	if item.Identifier == "" {
+		item.Identifier = uuid.NewString()
+	}
+	item.ListId = listID
+	if item.CategoryMatchId == "" {
+		item.CategoryMatchId = "other"
+	}
+	if item.UserId == "" {
+		item.UserId = c.userID
+	}
+
+	return &pb.PBListOperation{
+		Metadata:   c.metadata("add-shopping-list-item"),
+		ListId:     listID,
+		ListItemId: item.Identifier,
+		ListItem:   item,
+	}, nil
+}
+
+// RemoveItemOperation returns the operation that removes an item from a list,
+// for use with SendListOperations. Unlike RemoveItem, the whole item is
+// included in the operation, so it can be inverted to put the item back.
+func (c *Client) RemoveItemOperation(listID string, item *pb.ListItem) *pb.PBListOperation {
+	item = proto.Clone(item).(*pb.ListItem)
+	item.ListId = listID
+	return &pb.PBListOperation{
+		Metadata:   c.metadata("remove-shopping-list-item"),
+		ListId:     listID,
+		ListItemId: item.Identifier,
+		ListItem:   item,
+	}
+}
+
+// ClearCheckedItems removes every checked item from a list, in a single batch.
+func (c *Client) ClearCheckedItems(ctx context.Context, list *pb.ShoppingList) error {
+	if err := c.SendListOperations(ctx, c.ClearCheckedOperations(list)...); err != nil {
+		return fmt.Errorf("failed to clear checked items: %w", err)
+	}
+	return nil
+}
+
+// ClearCheckedOperations returns the operations that remove every checked
+// item from a list, for use with SendListOperations. See ClearCheckedItems.
+func (c *Client) ClearCheckedOperations(list *pb.ShoppingList) []*pb.PBListOperation {
+	var ops []*pb.PBListOperation
+	for _, item := range list.Items {
+		if item.Checked {
+			ops = append(ops, c.RemoveItemOperation(list.Identifier, item))
+		}
+	}
+	return ops
+}
+
+// SetAllChecked checks or unchecks every item on a list, in a single batch.
+func (c *Client) SetAllChecked(ctx context.Context, list *pb.ShoppingList, checked bool) error {
+	if err := c.SendListOperations(ctx, c.SetAllCheckedOperations(list, checked)...); err != nil {
+		return fmt.Errorf("failed to set checked on all items: %w", err)
+	}
+	return nil
+}
+
+// SetAllCheckedOperations returns the operations that check or uncheck every
+// item on a list, for use with SendListOperations. Items that are already in
+// that state are left alone. See SetAllChecked.
+func (c *Client) SetAllCheckedOperations(list *pb.ShoppingList, checked bool) []*pb.PBListOperation {
+	var ops []*pb.PBListOperation
+	for _, item := range list.Items {
+		if item.Checked == checked {
+			continue
+		}
+		ops = append(ops, &pb.PBListOperation{
+			Metadata:      c.metadata("set-list-item-checked"),
+			ListId:        list.Identifier,
+			ListItemId:    item.Identifier,
+			UpdatedValue:  checkedValue(checked),
+			OriginalValue: checkedValue(item.Checked),
+		})
+	}
+	return ops
+}
+
+// ItemUpdate describes changes to a list item. Fields that are nil are left
+// as they are.
+type ItemUpdate struct {
+	Name     *string
+	Quantity *string
+	Details  *string
+	Checked  *bool
+
+	// Original is the item as the caller last saw it. If it's set, its values
+	// are sent along with the changes, so AnyList can tell if someone else
+	// changed the item in the meantime.
+	Original *pb.ListItem
+}
+
+// UpdateItem applies the changes in u to an item, in a single batch.
+func (c *Client) UpdateItem(ctx context.Context, listID, itemID string, u ItemUpdate) error {
+	ops, err := c.UpdateItemOperations(listID, itemID, u)
+	if err != nil {
+		return err
+	}
+	if err := c.SendListOperations(ctx, ops...); err != nil {
+		return fmt.Errorf("failed to update item: %w", err)
+	}
+	return nil
+}
+
+// UpdateItemOperations returns the operations that make the changes in u to
+// an item, for use with SendListOperations. See UpdateItem.
+func (c *Client) UpdateItemOperations(listID, itemID string, u ItemUpdate) ([]*pb.PBListOperation, error) {
+	var ops []*pb.PBListOperation
+	set := func(handlerID, value, original string) {
+		op := &pb.PBListOperation{
+			Metadata:     c.metadata(handlerID),
+			ListId:       listID,
+			ListItemId:   itemID,
+			UpdatedValue: value,
+		}
+		if u.Original != nil {
+			op.OriginalValue = original
+		}
+		ops = append(ops, op)
+	}
+	orig := u.Original
+	if orig == nil {
+		orig = &pb.ListItem{}
+	}
+	if u.Name != nil {
+		if *u.Name == "" {
+			return nil, errors.New("item name can't be empty")
+		}
+		set("set-list-item-name", *u.Name, orig.Name)
+	}
+	if u.Quantity != nil {
+		set("set-list-item-quantity", *u.Quantity, orig.Quantity)
+	}
+	if u.Details != nil {
+		set("set-list-item-details", *u.Details, orig.Details)
+	}
+	if u.Checked != nil {
+		set("set-list-item-checked", checkedValue(*u.Checked), checkedValue(orig.Checked))
+	}
+	return ops, nil
+}
+
+// Apply makes the changes in u to item.
+func (u ItemUpdate) Apply(item *pb.ListItem) {
+	if u.Name != nil {
+		item.Name = *u.Name
+	}
+	if u.Quantity != nil {
+		item.Quantity = *u.Quantity
+	}
+	if u.Details != nil {
+		item.Details = *u.Details
+	}
+	if u.Checked != nil {
+		item.Checked = *u.Checked
+	}
+}
+
+// SendListOperations sends list operations to AnyList in a single batch. It
+// returns an error wrapping ErrNotProcessed if AnyList reports that it didn't
+// apply some of them.
+func (c *Client) SendListOperations(ctx context.Context, ops ...*pb.PBListOperation) error {
+	if len(ops) == 0 {
+		return nil
+	}
+	var resp pb.PBEditOperationResponse
+	if err := c.postOperationsWithResponse(ctx, listUpdatePath, &pb.PBListOperationList{Operations: ops}, &resp); err != nil {
+		return err
+	}
+	// Responses that don't list any processed operations don't tell us
+	// either way.
+	if len(resp.ProcessedOperations) == 0 {
+		return nil
+	}
+	processed := make(map[string]bool)
+	for _, id := range resp.ProcessedOperations {
+		processed[id] = true
+	}
+	missing := 0
+	for _, op := range ops {
+		if !processed[op.GetMetadata().GetOperationId()] {
+			missing++
+		}
+	}
+	if missing > 0 {
+		return fmt.Errorf("%w: %d of %d operations", ErrNotProcessed, missing, len(ops))
+	}
+	return nil
+}
+
+// checkedValue is how AnyList represents an item's checked state in an
+// operation's value.
+func checkedValue(checked bool) string {
+	if checked {
+		return "y"
+	}
+	return "n"
+}
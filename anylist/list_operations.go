@@ -0,0 +1,154 @@
+package anylist
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bcspragu/anylist/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrNotInvertible is returned by InvertListOperation for operations that
+// don't record enough to be undone.
+var ErrNotInvertible = errors.New("operation can't be inverted")
+
+// itemField gets and sets one of an item's fields as an operation's value.
+type itemField struct {
+	get func(item *pb.ListItem) string
+	set func(item *pb.ListItem, value string)
+}
+
+// itemFields maps the handlers for operations that set one of an item's
+// fields to that field.
+var itemFields = map[string]itemField{
+	"set-list-item-name": {
+		get: func(item *pb.ListItem) string { return item.Name },
+		set: func(item *pb.ListItem, v string) { item.Name = v },
+	},
+	"set-list-item-quantity": {
+		get: func(item *pb.ListItem) string { return item.Quantity },
+		set: func(item *pb.ListItem, v string) { item.Quantity = v },
+	},
+	"set-list-item-details": {
+		get: func(item *pb.ListItem) string { return item.Details },
+		set: func(item *pb.ListItem, v string) { item.Details = v },
+	},
+	"set-list-item-checked": {
+		get: func(item *pb.ListItem) string { return checkedValue(item.Checked) },
+		set: func(item *pb.ListItem, v string) { item.Checked = v == "y" },
+	},
+}
+
+// InvertListOperation returns the operation that undoes op: adding an item
+// is undone by removing it, removing an item by adding it back, and setting a
+// field by setting it back to its original value. Removals must include the
+// whole item, and field changes their original value, see RemoveItemOperation
+// and ItemUpdate.Original.
+//
+// The returned operation needs new metadata before it's sent, see
+// Client.NewOperation.
+func InvertListOperation(op *pb.PBListOperation) (*pb.PBListOperation, error) {
+	handlerID := op.GetMetadata().GetHandlerId()
+	inv := &pb.PBListOperation{
+		ListId:     op.ListId,
+		ListItemId: op.ListItemId,
+	}
+	switch handlerID {
+	case "add-shopping-list-item":
+		inv.Metadata = &pb.PBOperationMetadata{HandlerId: "remove-shopping-list-item"}
+		inv.ListItem = proto.Clone(op.ListItem).(*pb.ListItem)
+	case "remove-shopping-list-item":
+		if op.GetListItem().GetName() == "" {
+			return nil, fmt.Errorf("%w: removal doesn't include the item", ErrNotInvertible)
+		}
+		inv.Metadata = &pb.PBOperationMetadata{HandlerId: "add-shopping-list-item"}
+		inv.ListItem = proto.Clone(op.ListItem).(*pb.ListItem)
+	default:
+		if _, ok := itemFields[handlerID]; !ok {
+			return nil, fmt.Errorf("%w: unsupported handler %q", ErrNotInvertible, handlerID)
+		}
+		// Names and checked states are never empty, so an empty original value
+		// means it wasn't recorded.
+		if op.OriginalValue == "" && (handlerID == "set-list-item-name" || handlerID == "set-list-item-checked") {
+			return nil, fmt.Errorf("%w: %s doesn't include the original value", ErrNotInvertible, handlerID)
+		}
+		inv.Metadata = &pb.PBOperationMetadata{HandlerId: handlerID}
+		inv.UpdatedValue = op.OriginalValue
+		inv.OriginalValue = op.UpdatedValue
+	}
+	return inv, nil
+}
+
+// NewOperation gives an operation, like one from InvertListOperation, fresh
+// metadata from this client, keeping its handler.
+func (c *Client) NewOperation(op *pb.PBListOperation) *pb.PBListOperation {
+	op = proto.Clone(op).(*pb.PBListOperation)
+	op.Metadata = c.metadata(op.GetMetadata().GetHandlerId())
+	return op
+}
+
+// ApplyListOperation makes the change described by op to a local copy of a
+// list, e.g. to update a cached copy of the list after sending op to AnyList.
+func ApplyListOperation(list *pb.ShoppingList, op *pb.PBListOperation) error {
+	handlerID := op.GetMetadata().GetHandlerId()
+	idx := -1
+	for i, item := range list.Items {
+		if item.Identifier == op.ListItemId {
+			idx = i
+			break
+		}
+	}
+
+	switch handlerID {
+	case "add-shopping-list-item":
+		item := proto.Clone(op.ListItem).(*pb.ListItem)
+		if idx == -1 {
+			list.Items = append(list.Items, item)
+		} else {
+			list.Items[idx] = item
+		}
+	case "remove-shopping-list-item":
+		if idx != -1 {
+			list.Items = append(list.Items[:idx:idx], list.Items[idx+1:]...)
+		}
+	default:
+		field, ok := itemFields[handlerID]
+		if !ok {
+			return fmt.Errorf("unsupported handler %q", handlerID)
+		}
+		if idx == -1 {
+			return fmt.Errorf("item %q not found", op.ListItemId)
+		}
+		field.set(list.Items[idx], op.UpdatedValue)
+	}
+	return nil
+}
+
+// ListOperationCurrent reports whether the change op made is still in place on
+// list, i.e. nobody has changed the item since. Added items must still be on
+// the list as they were added, removed items must still be gone, and changed
+// fields must still have the value they were set to.
+func ListOperationCurrent(list *pb.ShoppingList, op *pb.PBListOperation) bool {
+	var item *pb.ListItem
+	for _, it := range list.Items {
+		if it.Identifier == op.ListItemId {
+			item = it
+			break
+		}
+	}
+
+	switch handlerID := op.GetMetadata().GetHandlerId(); handlerID {
+	case "add-shopping-list-item":
+		added := op.GetListItem()
+		return item != nil &&
+			item.Name == added.GetName() &&
+			item.Quantity == added.GetQuantity() &&
+			item.Details == added.GetDetails() &&
+			item.Checked == added.GetChecked()
+	case "remove-shopping-list-item":
+		return item == nil
+	default:
+		field, ok := itemFields[handlerID]
+		return ok && item != nil && field.get(item) == op.UpdatedValue
+	}
+}
@@ -0,0 +1,151 @@
+package anylist
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/bcspragu/anylist/pb"
+)
+
+// DateLayout is the format AnyList uses for meal plan event dates.
+const DateLayout = "2006-01-02"
+
+// MealPlan provides typed access to the meal planning calendar, which AnyList
+// includes in every user data response.
+type MealPlan struct {
+	resp    *pb.PBCalendarResponse
+	recipes *RecipeData
+	labels  map[string]*pb.PBCalendarLabel
+}
+
+// MealPlanEvent is a meal plan event with its label and recipe resolved.
+type MealPlanEvent struct {
+	Event *pb.PBCalendarEvent
+	// Date is the parsed form of the event's date, at midnight UTC.
+	Date time.Time
+	// Label is nil if the event isn't labeled.
+	Label *pb.PBCalendarLabel
+	// Recipe is nil if the event isn't linked to a recipe, or if the recipe
+	// no longer exists.
+	Recipe *pb.PBRecipe
+}
+
+// NewMealPlan wraps the meal planning calendar portion of a user data
+// response, as returned by Lists.
+func NewMealPlan(in *pb.PBUserDataResponse) *MealPlan {
+	resp := in.GetMealPlanningCalendarResponse()
+	if resp == nil {
+		resp = &pb.PBCalendarResponse{}
+	}
+	mp := &MealPlan{
+		resp:    resp,
+		recipes: NewRecipeData(in),
+		labels:  make(map[string]*pb.PBCalendarLabel),
+	}
+	for _, l := range resp.Labels {
+		mp.labels[l.Identifier] = l
+	}
+	return mp
+}
+
+// MealPlan loads the user's meal planning calendar.
+func (c *Client) MealPlan(ctx context.Context) (*MealPlan, error) {
+	resp, err := c.Lists(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user data: %w", err)
+	}
+	return NewMealPlan(resp), nil
+}
+
+// CalendarID returns the identifier of the meal planning calendar, which is
+// needed when sending calendar operations.
+func (mp *MealPlan) CalendarID() string {
+	return mp.resp.CalendarId
+}
+
+// Labels returns the calendar's labels (e.g. "Breakfast", "Dinner"), in the
+// order the user arranged them.
+func (mp *MealPlan) Labels() []*pb.PBCalendarLabel {
+	out := make([]*pb.PBCalendarLabel, len(mp.resp.Labels))
+	copy(out, mp.resp.Labels)
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].SortIndex < out[j].SortIndex
+	})
+	return out
+}
+
+// Label returns the label with the given ID.
+func (mp *MealPlan) Label(id string) (*pb.PBCalendarLabel, bool) {
+	l, ok := mp.labels[id]
+	return l, ok
+}
+
+// Event returns the event with the given ID.
+func (mp *MealPlan) Event(id string) (*MealPlanEvent, bool) {
+	for _, e := range mp.resp.Events {
+		if e.Identifier == id {
+			return mp.resolve(e), true
+		}
+	}
+	return nil, false
+}
+
+// Events returns the events between from and to, inclusive, ordered by date,
+// then by label, then by the order they were added. Only the dates of from
+// and to matter, not their times.
+func (mp *MealPlan) Events(from, to time.Time) []*MealPlanEvent {
+	start, end := from.Format(DateLayout), to.Format(DateLayout)
+
+	var out []*MealPlanEvent
+	for _, e := range mp.resp.Events {
+		// Dates are zero-padded, so they sort lexically.
+		if e.Date < start || e.Date > end {
+			continue
+		}
+		out = append(out, mp.resolve(e))
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Event.Date != b.Event.Date {
+			return a.Event.Date < b.Event.Date
+		}
+		if la, lb := labelSortIndex(a.Label), labelSortIndex(b.Label); la != lb {
+			return la < lb
+		}
+		return a.Event.OrderAddedSortIndex < b.Event.OrderAddedSortIndex
+	})
+	return out
+}
+
+func (mp *MealPlan) resolve(e *pb.PBCalendarEvent) *MealPlanEvent {
+	out := &MealPlanEvent{Event: e}
+	if d, err := time.Parse(DateLayout, e.Date); err == nil {
+		out.Date = d
+	}
+	if e.LabelId != "" {
+		out.Label = mp.labels[e.LabelId]
+	}
+	if e.RecipeId != "" {
+		out.Recipe, _ = mp.recipes.Recipe(e.RecipeId)
+	}
+	return out
+}
+
+// Title returns the event's title, falling back to the name of its recipe.
+func (e *MealPlanEvent) Title() string {
+	if e.Event.Title != "" || e.Recipe == nil {
+		return e.Event.Title
+	}
+	return e.Recipe.Name
+}
+
+// labelSortIndex sorts unlabeled events after labeled ones.
+func labelSortIndex(l *pb.PBCalendarLabel) int32 {
+	if l == nil {
+		return 1<<31 - 1
+	}
+	return l.SortIndex
+}
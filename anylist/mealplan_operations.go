@@ -0,0 +1,287 @@
+package anylist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bcspragu/anylist/pb"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+)
+
+const mealPlanUpdatePath = "/data/meal-planning-calendar/update"
+
+// AddMealPlanEvent adds an event to the meal planning calendar identified by
+// calendarID (see MealPlan.CalendarID). The event's date should be formatted
+// with DateLayout. To plan a recipe, set the event's RecipeId and optionally
+// its RecipeScaleFactor. The saved event is returned.
+func (c *Client) AddMealPlanEvent(ctx context.Context, calendarID string, event *pb.PBCalendarEvent) (*pb.PBCalendarEvent, error) {
+	event = newEvent(calendarID, event)
+	if _, err := time.Parse(DateLayout, event.Date); err != nil {
+		return nil, fmt.Errorf("invalid event date %q: %w", event.Date, err)
+	}
+
+	err := c.sendCalendarOperations(ctx, &pb.PBCalendarOperation{
+		Metadata:     c.metadata("new-event"),
+		CalendarId:   calendarID,
+		UpdatedEvent: event,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to add meal plan event: %w", err)
+	}
+	return event, nil
+}
+
+// AddRecipeToMealPlan plans a recipe on the given date, scaled by
+// scaleFactor. labelID may be empty.
+func (c *Client) AddRecipeToMealPlan(ctx context.Context, calendarID string, date time.Time, recipeID, labelID string, scaleFactor float64) (*pb.PBCalendarEvent, error) {
+	if scaleFactor == 0 {
+		scaleFactor = 1
+	}
+	return c.AddMealPlanEvent(ctx, calendarID, &pb.PBCalendarEvent{
+		Date:              date.Format(DateLayout),
+		RecipeId:          recipeID,
+		LabelId:           labelID,
+		RecipeScaleFactor: scaleFactor,
+	})
+}
+
+// UpdateMealPlanEvent replaces an event with an updated version, e.g. with a
+// new title, details, label or recipe. original should be the event as last
+// loaded, so AnyList can detect conflicting edits.
+func (c *Client) UpdateMealPlanEvent(ctx context.Context, calendarID string, original, updated *pb.PBCalendarEvent) error {
+	if updated.Identifier == "" || updated.Identifier != original.Identifier {
+		return errors.New("updated event must have the same identifier as the original")
+	}
+	err := c.sendCalendarOperations(ctx, &pb.PBCalendarOperation{
+		Metadata:      c.metadata("update-event"),
+		CalendarId:    calendarID,
+		UpdatedEvent:  updated,
+		OriginalEvent: original,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update meal plan event: %w", err)
+	}
+	return nil
+}
+
+// MoveMealPlanEvent moves an event to a different date, returning the moved
+// event.
+func (c *Client) MoveMealPlanEvent(ctx context.Context, calendarID string, event *pb.PBCalendarEvent, date time.Time) (*pb.PBCalendarEvent, error) {
+	updated := proto.Clone(event).(*pb.PBCalendarEvent)
+	updated.Date = date.Format(DateLayout)
+	if err := c.UpdateMealPlanEvent(ctx, calendarID, event, updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// UpdateMealPlanEvents updates many events in a single operation. originals
+// and updated must be the same length, with matching identifiers at each
+// index.
+func (c *Client) UpdateMealPlanEvents(ctx context.Context, calendarID string, originals, updated []*pb.PBCalendarEvent) error {
+	if len(originals) != len(updated) {
+		return fmt.Errorf("got %d original events, but %d updated events", len(originals), len(updated))
+	}
+	var ids []string
+	for i, e := range updated {
+		if e.Identifier == "" || e.Identifier != originals[i].Identifier {
+			return fmt.Errorf("updated event %d must have the same identifier as the original", i)
+		}
+		ids = append(ids, e.Identifier)
+	}
+
+	err := c.sendCalendarOperations(ctx, &pb.PBCalendarOperation{
+		Metadata:       c.metadata("bulk-update-events"),
+		CalendarId:     calendarID,
+		EventIds:       ids,
+		UpdatedEvents:  updated,
+		OriginalEvents: originals,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update meal plan events: %w", err)
+	}
+	return nil
+}
+
+// CopyMealPlanEvents copies events to new events the given number of days
+// later (or earlier, if negative), in a single batch. For example, copying
+// last week's dinners to this week is a copy with days set to 7. The new
+// events are returned.
+func (c *Client) CopyMealPlanEvents(ctx context.Context, calendarID string, events []*pb.PBCalendarEvent, days int) ([]*pb.PBCalendarEvent, error) {
+	var (
+		ops    []*pb.PBCalendarOperation
+		copies []*pb.PBCalendarEvent
+	)
+	for _, e := range events {
+		d, err := time.Parse(DateLayout, e.Date)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q for event %q: %w", e.Date, e.Identifier, err)
+		}
+		cp := proto.Clone(e).(*pb.PBCalendarEvent)
+		cp.Identifier = ""
+		cp.Date = d.AddDate(0, 0, days).Format(DateLayout)
+		cp = newEvent(calendarID, cp)
+
+		ops = append(ops, &pb.PBCalendarOperation{
+			Metadata:     c.metadata("new-event"),
+			CalendarId:   calendarID,
+			UpdatedEvent: cp,
+		})
+		copies = append(copies, cp)
+	}
+	if len(ops) == 0 {
+		return nil, nil
+	}
+
+	if err := c.sendCalendarOperations(ctx, ops...); err != nil {
+		return nil, fmt.Errorf("failed to copy meal plan events: %w", err)
+	}
+	return copies, nil
+}
+
+// DeleteMealPlanEvent removes an event from the calendar.
+func (c *Client) DeleteMealPlanEvent(ctx context.Context, calendarID string, event *pb.PBCalendarEvent) error {
+	err := c.sendCalendarOperations(ctx, &pb.PBCalendarOperation{
+		Metadata:      c.metadata("delete-event"),
+		CalendarId:    calendarID,
+		UpdatedEvent:  &pb.PBCalendarEvent{Identifier: event.Identifier, CalendarId: calendarID},
+		OriginalEvent: event,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete meal plan event: %w", err)
+	}
+	return nil
+}
+
+// CreateMealPlanLabel creates a new label with the given name and color
+// (e.g. "#FF8800") at the given position. The new label is returned.
+func (c *Client) CreateMealPlanLabel(ctx context.Context, calendarID, name, hexColor string, sortIndex int32) (*pb.PBCalendarLabel, error) {
+	label := &pb.PBCalendarLabel{
+		Identifier: uuid.NewString(),
+		CalendarId: calendarID,
+		Name:       name,
+		HexColor:   hexColor,
+		SortIndex:  sortIndex,
+	}
+	err := c.sendCalendarOperations(ctx, &pb.PBCalendarOperation{
+		Metadata:     c.metadata("new-label"),
+		CalendarId:   calendarID,
+		UpdatedLabel: label,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create meal plan label: %w", err)
+	}
+	return label, nil
+}
+
+// UpdateMealPlanLabel replaces a label with an updated version, e.g. to
+// rename or recolor it.
+func (c *Client) UpdateMealPlanLabel(ctx context.Context, calendarID string, original, updated *pb.PBCalendarLabel) error {
+	if updated.Identifier == "" || updated.Identifier != original.Identifier {
+		return errors.New("updated label must have the same identifier as the original")
+	}
+	err := c.sendCalendarOperations(ctx, &pb.PBCalendarOperation{
+		Metadata:      c.metadata("update-label"),
+		CalendarId:    calendarID,
+		UpdatedLabel:  updated,
+		OriginalLabel: original,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update meal plan label: %w", err)
+	}
+	return nil
+}
+
+// RecolorMealPlanLabel changes a label's color, returning the updated label.
+func (c *Client) RecolorMealPlanLabel(ctx context.Context, calendarID string, label *pb.PBCalendarLabel, hexColor string) (*pb.PBCalendarLabel, error) {
+	updated := proto.Clone(label).(*pb.PBCalendarLabel)
+	updated.HexColor = hexColor
+	if err := c.UpdateMealPlanLabel(ctx, calendarID, label, updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// DeleteMealPlanLabel removes a label. Events with the label become
+// unlabeled.
+func (c *Client) DeleteMealPlanLabel(ctx context.Context, calendarID string, label *pb.PBCalendarLabel) error {
+	err := c.sendCalendarOperations(ctx, &pb.PBCalendarOperation{
+		Metadata:      c.metadata("delete-label"),
+		CalendarId:    calendarID,
+		UpdatedLabel:  &pb.PBCalendarLabel{Identifier: label.Identifier, CalendarId: calendarID},
+		OriginalLabel: label,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete meal plan label: %w", err)
+	}
+	return nil
+}
+
+// ReorderMealPlanLabels sets the order labels are displayed in.
+func (c *Client) ReorderMealPlanLabels(ctx context.Context, calendarID string, labelIDs []string) error {
+	err := c.sendCalendarOperations(ctx, &pb.PBCalendarOperation{
+		Metadata:       c.metadata("sort-labels"),
+		CalendarId:     calendarID,
+		SortedLabelIds: labelIDs,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reorder meal plan labels: %w", err)
+	}
+	return nil
+}
+
+// sendCalendarOperations sends meal plan operations, which are a premium
+// feature.
+func (c *Client) sendCalendarOperations(ctx context.Context, ops ...*pb.PBCalendarOperation) error {
+	if err := c.requirePremium(ctx, "meal planning"); err != nil {
+		return err
+	}
+	return c.postOperations(ctx, mealPlanUpdatePath, &pb.PBCalendarOperationList{Operations: ops})
+}
+
+// newEvent returns a copy of the event ready to be added to the calendar.
+func newEvent(calendarID string, event *pb.PBCalendarEvent) *pb.PBCalendarEvent {
+	event = proto.Clone(event).(*pb.PBCalendarEvent)
+	if event.Identifier == "" {
+		event.Identifier = uuid.NewString()
+	}
+	event.CalendarId = calendarID
+	if event.RecipeId != "" && event.RecipeScaleFactor == 0 {
+		event.RecipeScaleFactor = 1
+	}
+	return event
+}
+
+// AddMealPlanToList builds a shopping list from meal plan events (e.g. from
+// MealPlan.Events): every linked recipe's ingredients are scaled by the
+// event's recipe scale factor, duplicate ingredients across recipes are
+// combined with their quantities summed where the units allow, and the
+// result is added to the list in a single batch. Ingredients that are already
+// on the list and unchecked are skipped. The added items are returned.
+func (c *Client) AddMealPlanToList(ctx context.Context, list *pb.ShoppingList, events []*MealPlanEvent) ([]*pb.ListItem, error) {
+	ops, err := c.AddMealPlanToListOperations(list, events)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add meal plan ingredients: %w", err)
+	}
+	if err := c.SendListOperations(ctx, ops...); err != nil {
+		return nil, fmt.Errorf("failed to add meal plan ingredients: %w", err)
+	}
+	return AddedItems(ops), nil
+}
+
+// AddMealPlanToListOperations returns the operations that add the ingredients
+// for meal plan events to a list, for use with SendListOperations. See
+// AddMealPlanToList.
+func (c *Client) AddMealPlanToListOperations(list *pb.ShoppingList, events []*MealPlanEvent) ([]*pb.PBListOperation, error) {
+	var ings []listIngredient
+	for _, e := range events {
+		if e.Recipe == nil {
+			continue
+		}
+		ings = append(ings, recipeIngredients(e.Recipe, e.Event.RecipeScaleFactor, e.Event.Identifier)...)
+	}
+	return c.addIngredientsOperations(list, ings)
+}
@@ -0,0 +1,336 @@
+package anylist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bcspragu/anylist/ingredient"
+	"github.com/bcspragu/anylist/pb"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+)
+
+const recipeDataUpdatePath = "/data/user-recipe-data/update"
+
+// CreateRecipe saves a new recipe to the recipe box identified by
+// recipeDataID (see RecipeData.ID). If the recipe doesn't have an identifier,
+// one is generated. The saved recipe is returned.
+func (c *Client) CreateRecipe(ctx context.Context, recipeDataID string, recipe *pb.PBRecipe) (*pb.PBRecipe, error) {
+	recipe = proto.Clone(recipe).(*pb.PBRecipe)
+	if recipe.Identifier == "" {
+		recipe.Identifier = uuid.NewString()
+	}
+	now := Timestamp(time.Now())
+	if recipe.CreationTimestamp == 0 {
+		recipe.CreationTimestamp = now
+	}
+	recipe.Timestamp = now
+
+	if err := c.saveRecipe(ctx, recipeDataID, recipe); err != nil {
+		return nil, fmt.Errorf("failed to create recipe: %w", err)
+	}
+	return recipe, nil
+}
+
+// UpdateRecipe replaces an existing recipe with the given one, matched by
+// identifier.
+func (c *Client) UpdateRecipe(ctx context.Context, recipeDataID string, recipe *pb.PBRecipe) (*pb.PBRecipe, error) {
+	if recipe.Identifier == "" {
+		return nil, errors.New("recipe to update has no identifier")
+	}
+	recipe = proto.Clone(recipe).(*pb.PBRecipe)
+	recipe.Timestamp = Timestamp(time.Now())
+
+	if err := c.saveRecipe(ctx, recipeDataID, recipe); err != nil {
+		return nil, fmt.Errorf("failed to update recipe: %w", err)
+	}
+	return recipe, nil
+}
+
+func (c *Client) saveRecipe(ctx context.Context, recipeDataID string, recipe *pb.PBRecipe) error {
+	return c.sendRecipeOperations(ctx, &pb.PBRecipeOperation{
+		Metadata:     c.metadata("save-recipe"),
+		RecipeDataId: recipeDataID,
+		Recipe:       recipe,
+	})
+}
+
+// DeleteRecipe removes a recipe from the recipe box.
+func (c *Client) DeleteRecipe(ctx context.Context, recipeDataID, recipeID string) error {
+	err := c.sendRecipeOperations(ctx, &pb.PBRecipeOperation{
+		Metadata:     c.metadata("remove-recipe"),
+		RecipeDataId: recipeDataID,
+		Recipe:       &pb.PBRecipe{Identifier: recipeID},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete recipe: %w", err)
+	}
+	return nil
+}
+
+// CreateRecipeCollection creates a new, empty recipe collection with the given
+// name and returns it.
+func (c *Client) CreateRecipeCollection(ctx context.Context, recipeDataID, name string) (*pb.PBRecipeCollection, error) {
+	col := &pb.PBRecipeCollection{
+		Identifier:         uuid.NewString(),
+		Timestamp:          Timestamp(time.Now()),
+		Name:               name,
+		CollectionSettings: &pb.PBRecipeCollectionSettings{},
+	}
+	err := c.sendRecipeOperations(ctx, &pb.PBRecipeOperation{
+		Metadata:         c.metadata("new-recipe-collection"),
+		RecipeDataId:     recipeDataID,
+		RecipeCollection: col,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recipe collection: %w", err)
+	}
+	return col, nil
+}
+
+// DeleteRecipeCollection removes a recipe collection. The recipes in it are
+// not deleted.
+func (c *Client) DeleteRecipeCollection(ctx context.Context, recipeDataID, collectionID string) error {
+	err := c.sendRecipeOperations(ctx, &pb.PBRecipeOperation{
+		Metadata:         c.metadata("remove-recipe-collection"),
+		RecipeDataId:     recipeDataID,
+		RecipeCollection: &pb.PBRecipeCollection{Identifier: collectionID},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete recipe collection: %w", err)
+	}
+	return nil
+}
+
+// AddRecipesToCollection adds existing recipes to a recipe collection.
+func (c *Client) AddRecipesToCollection(ctx context.Context, recipeDataID, collectionID string, recipeIDs ...string) error {
+	err := c.sendRecipeOperations(ctx, &pb.PBRecipeOperation{
+		Metadata:         c.metadata("add-recipes-to-collection"),
+		RecipeDataId:     recipeDataID,
+		RecipeCollection: &pb.PBRecipeCollection{Identifier: collectionID},
+		RecipeIds:        recipeIDs,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add recipes to collection: %w", err)
+	}
+	return nil
+}
+
+// RemoveRecipesFromCollection removes recipes from a recipe collection,
+// without deleting them.
+func (c *Client) RemoveRecipesFromCollection(ctx context.Context, recipeDataID, collectionID string, recipeIDs ...string) error {
+	err := c.sendRecipeOperations(ctx, &pb.PBRecipeOperation{
+		Metadata:         c.metadata("remove-recipes-from-collection"),
+		RecipeDataId:     recipeDataID,
+		RecipeCollection: &pb.PBRecipeCollection{Identifier: collectionID},
+		RecipeIds:        recipeIDs,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove recipes from collection: %w", err)
+	}
+	return nil
+}
+
+// SetRecipeCollectionSettings updates how a recipe collection is displayed,
+// e.g. its sort order.
+func (c *Client) SetRecipeCollectionSettings(ctx context.Context, recipeDataID, collectionID string, settings *pb.PBRecipeCollectionSettings) error {
+	err := c.sendRecipeOperations(ctx, &pb.PBRecipeOperation{
+		Metadata:     c.metadata("set-recipe-collection-settings"),
+		RecipeDataId: recipeDataID,
+		RecipeCollection: &pb.PBRecipeCollection{
+			Identifier:         collectionID,
+			CollectionSettings: settings,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set recipe collection settings: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) sendRecipeOperations(ctx context.Context, ops ...*pb.PBRecipeOperation) error {
+	return c.postOperations(ctx, recipeDataUpdatePath, &pb.PBRecipeOperationList{Operations: ops})
+}
+
+// Timestamp converts a time to AnyList's floating point seconds since the
+// epoch, as used for e.g. recipe timestamps.
+func Timestamp(t time.Time) float64 {
+	return float64(t.UnixNano()) / 1e9
+}
+
+// TimestampTime is the inverse of Timestamp.
+func TimestampTime(ts float64) time.Time {
+	sec, frac := math.Modf(ts)
+	return time.Unix(int64(sec), int64(frac*1e9))
+}
+
+// FormatRecipeTime renders a recipe's prep or cook time, which is in seconds,
+// like "1 hr 30 mins". It's empty for times under a minute.
+func FormatRecipeTime(secs int32) string {
+	d := time.Duration(secs) * time.Second
+	h := int(d / time.Hour)
+	m := int((d % time.Hour) / time.Minute)
+
+	var parts []string
+	switch {
+	case h == 1:
+		parts = append(parts, "1 hr")
+	case h > 1:
+		parts = append(parts, strconv.Itoa(h)+" hrs")
+	}
+	switch {
+	case m == 1:
+		parts = append(parts, "1 min")
+	case m > 1:
+		parts = append(parts, strconv.Itoa(m)+" mins")
+	}
+	return strings.Join(parts, " ")
+}
+
+// AddRecipeToList adds each of a recipe's ingredients to a shopping list as
+// an item linked back to the recipe, scaling quantities by scaleFactor (a
+// scale factor of zero uses the recipe's own). Ingredients that are already
+// on the list and unchecked are skipped, and ingredients the recipe lists
+// more than once are combined. All items are added in a single batch, and the
+// added items are returned.
+func (c *Client) AddRecipeToList(ctx context.Context, list *pb.ShoppingList, recipe *pb.PBRecipe, scaleFactor float64) ([]*pb.ListItem, error) {
+	ops, err := c.AddRecipeToListOperations(list, recipe, scaleFactor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add recipe ingredients: %w", err)
+	}
+	if err := c.SendListOperations(ctx, ops...); err != nil {
+		return nil, fmt.Errorf("failed to add recipe ingredients: %w", err)
+	}
+	return AddedItems(ops), nil
+}
+
+// AddRecipeToListOperations returns the operations that add a recipe's
+// ingredients to a list, for use with SendListOperations. See AddRecipeToList.
+func (c *Client) AddRecipeToListOperations(list *pb.ShoppingList, recipe *pb.PBRecipe, scaleFactor float64) ([]*pb.PBListOperation, error) {
+	if scaleFactor == 0 {
+		scaleFactor = recipe.ScaleFactor
+	}
+	return c.addIngredientsOperations(list, recipeIngredients(recipe, scaleFactor, ""))
+}
+
+// AddedItems returns the items added by operations, e.g. from
+// AddRecipeToListOperations.
+func AddedItems(ops []*pb.PBListOperation) []*pb.ListItem {
+	var out []*pb.ListItem
+	for _, op := range ops {
+		if op.GetMetadata().GetHandlerId() == "add-shopping-list-item" {
+			out = append(out, op.ListItem)
+		}
+	}
+	return out
+}
+
+// listIngredient is an ingredient to add to a list, and where it came from.
+type listIngredient struct {
+	ing      ingredient.Ingredient
+	raw      string
+	recipeID string
+	eventID  string
+}
+
+// recipeIngredients returns a recipe's ingredients, scaled by scaleFactor.
+func recipeIngredients(recipe *pb.PBRecipe, scaleFactor float64, eventID string) []listIngredient {
+	if scaleFactor == 0 {
+		scaleFactor = 1
+	}
+	var out []listIngredient
+	for _, in := range recipe.Ingredients {
+		ing := ingredient.FromPB(in)
+		if ing.Name == "" {
+			continue
+		}
+		raw := in.RawIngredient
+		if scaleFactor != 1 || raw == "" {
+			ing = ing.Scale(scaleFactor)
+			raw = ing.String()
+		}
+		out = append(out, listIngredient{
+			ing:      ing,
+			raw:      raw,
+			recipeID: recipe.Identifier,
+			eventID:  eventID,
+		})
+	}
+	return out
+}
+
+// addIngredientsOperations returns the operations that add ingredients to a
+// list, combining ingredients with the same name, and skipping anything that's
+// already on the list and unchecked.
+func (c *Client) addIngredientsOperations(list *pb.ShoppingList, ings []listIngredient) ([]*pb.PBListOperation, error) {
+	onList := make(map[string]bool)
+	for _, item := range list.Items {
+		if !item.Checked {
+			onList[ingredient.Key(item.Name)] = true
+		}
+	}
+
+	type group struct {
+		first listIngredient
+		raws  []string
+		ings  []ingredient.Ingredient
+		// sums holds the combined quantities, there's more than one if the
+		// ingredient appears with incompatible units, e.g. "1 cup" and "1 can".
+		sums []ingredient.Ingredient
+	}
+	var (
+		order  []string
+		groups = make(map[string]*group)
+	)
+	for _, li := range ings {
+		key := ingredient.Key(li.ing.Name)
+		if onList[key] {
+			continue
+		}
+		g, ok := groups[key]
+		if !ok {
+			g = &group{first: li}
+			groups[key] = g
+			order = append(order, key)
+		}
+		g.raws = append(g.raws, li.raw)
+		g.ings = append(g.ings, li.ing)
+	}
+	for _, g := range groups {
+		g.sums = ingredient.Combine(g.ings)
+	}
+
+	var ops []*pb.PBListOperation
+	for _, key := range order {
+		g := groups[key]
+
+		var quantities, notes []string
+		for _, sum := range g.sums {
+			if q := sum.QuantityString(); q != "" {
+				quantities = append(quantities, q)
+			}
+			if sum.Note != "" {
+				notes = append(notes, sum.Note)
+			}
+		}
+
+		op, err := c.AddItemOperation(list.Identifier, &pb.ListItem{
+			Name:          g.first.ing.Name,
+			Quantity:      strings.Join(quantities, " + "),
+			Details:       strings.Join(notes, "; "),
+			RecipeId:      g.first.recipeID,
+			EventId:       g.first.eventID,
+			RawIngredient: strings.Join(g.raws, "\n"),
+		})
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
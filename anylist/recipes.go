@@ -0,0 +1,272 @@
+package anylist
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bcspragu/anylist/pb"
+)
+
+// RecipeData provides typed access to the recipe box, which AnyList includes
+// in every user data response.
+type RecipeData struct {
+	resp *pb.PBRecipeDataResponse
+
+	recipesByID     map[string]*pb.PBRecipe
+	collectionsByID map[string]*pb.PBRecipeCollection
+}
+
+// NewRecipeData wraps the recipe portion of a user data response, as returned
+// by Lists.
+func NewRecipeData(in *pb.PBUserDataResponse) *RecipeData {
+	resp := in.GetRecipeDataResponse()
+	if resp == nil {
+		resp = &pb.PBRecipeDataResponse{}
+	}
+	rd := &RecipeData{
+		resp:            resp,
+		recipesByID:     make(map[string]*pb.PBRecipe),
+		collectionsByID: make(map[string]*pb.PBRecipeCollection),
+	}
+	for _, r := range resp.Recipes {
+		rd.recipesByID[r.Identifier] = r
+	}
+	for _, col := range resp.RecipeCollections {
+		rd.collectionsByID[col.Identifier] = col
+	}
+	if all := resp.AllRecipesCollection; all != nil {
+		rd.collectionsByID[all.Identifier] = all
+	}
+	return rd
+}
+
+// RecipeData loads the user's recipe box.
+func (c *Client) RecipeData(ctx context.Context) (*RecipeData, error) {
+	resp, err := c.Lists(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user data: %w", err)
+	}
+	return NewRecipeData(resp), nil
+}
+
+// ID returns the identifier of the recipe data container, which is needed
+// when sending recipe operations.
+func (rd *RecipeData) ID() string {
+	return rd.resp.RecipeDataId
+}
+
+// Recipes returns every recipe in the recipe box, sorted by name.
+func (rd *RecipeData) Recipes() []*pb.PBRecipe {
+	out := make([]*pb.PBRecipe, len(rd.resp.Recipes))
+	copy(out, rd.resp.Recipes)
+	SortRecipes(out, pb.PBRecipeCollectionSettings_AlphabeticalSortOrder)
+	return out
+}
+
+// Recipe returns the recipe with the given ID.
+func (rd *RecipeData) Recipe(id string) (*pb.PBRecipe, bool) {
+	r, ok := rd.recipesByID[id]
+	return r, ok
+}
+
+// AllRecipesCollection returns the special collection that contains every
+// recipe.
+func (rd *RecipeData) AllRecipesCollection() *pb.PBRecipeCollection {
+	return rd.resp.AllRecipesCollection
+}
+
+// Collections returns the user's recipe collections, in the order the user
+// arranged them. The all-recipes collection is not included.
+func (rd *RecipeData) Collections() []*pb.PBRecipeCollection {
+	var out []*pb.PBRecipeCollection
+	seen := make(map[string]bool)
+	for _, id := range rd.resp.RecipeCollectionIds {
+		col, ok := rd.collectionsByID[id]
+		if !ok || seen[id] || rd.isAllRecipes(id) {
+			continue
+		}
+		seen[id] = true
+		out = append(out, col)
+	}
+	// Include anything that wasn't in the ordered IDs at the end.
+	for _, col := range rd.resp.RecipeCollections {
+		if seen[col.Identifier] || rd.isAllRecipes(col.Identifier) {
+			continue
+		}
+		seen[col.Identifier] = true
+		out = append(out, col)
+	}
+	return out
+}
+
+// Collection returns the collection with the given ID, which may be the
+// all-recipes collection.
+func (rd *RecipeData) Collection(id string) (*pb.PBRecipeCollection, bool) {
+	col, ok := rd.collectionsByID[id]
+	return col, ok
+}
+
+// CollectionRecipes returns the recipes in a collection, sorted according to
+// the collection's settings.
+func (rd *RecipeData) CollectionRecipes(id string) ([]*pb.PBRecipe, error) {
+	col, ok := rd.collectionsByID[id]
+	if !ok {
+		return nil, fmt.Errorf("no recipe collection with ID %q", id)
+	}
+	order := pb.PBRecipeCollectionSettings_SortOrder(col.GetCollectionSettings().GetRecipesSortOrder())
+	return rd.CollectionRecipesSorted(id, order)
+}
+
+// CollectionRecipesSorted returns the recipes in a collection, sorted by the
+// given order instead of the collection's own setting.
+func (rd *RecipeData) CollectionRecipesSorted(id string, order pb.PBRecipeCollectionSettings_SortOrder) ([]*pb.PBRecipe, error) {
+	col, ok := rd.collectionsByID[id]
+	if !ok {
+		return nil, fmt.Errorf("no recipe collection with ID %q", id)
+	}
+
+	var out []*pb.PBRecipe
+	if rd.isAllRecipes(id) {
+		out = rd.allRecipes(col)
+	} else {
+		for _, rID := range col.RecipeIds {
+			if r, ok := rd.recipesByID[rID]; ok {
+				out = append(out, r)
+			}
+		}
+	}
+
+	SortRecipes(out, order)
+	return out, nil
+}
+
+// allRecipes returns the contents of the all-recipes collection, which
+// includes recipes it doesn't explicitly list and can be configured to only
+// show recipes that aren't in any other collection.
+func (rd *RecipeData) allRecipes(col *pb.PBRecipeCollection) []*pb.PBRecipe {
+	var out []*pb.PBRecipe
+	seen := make(map[string]bool)
+	for _, rID := range col.RecipeIds {
+		if r, ok := rd.recipesByID[rID]; ok && !seen[rID] {
+			seen[rID] = true
+			out = append(out, r)
+		}
+	}
+	for _, r := range rd.resp.Recipes {
+		if !seen[r.Identifier] {
+			seen[r.Identifier] = true
+			out = append(out, r)
+		}
+	}
+
+	if !col.GetCollectionSettings().GetShowOnlyRecipesWithNoCollection() {
+		return out
+	}
+
+	inCollection := make(map[string]bool)
+	for _, other := range rd.resp.RecipeCollections {
+		if rd.isAllRecipes(other.Identifier) {
+			continue
+		}
+		for _, rID := range other.RecipeIds {
+			inCollection[rID] = true
+		}
+	}
+	var filtered []*pb.PBRecipe
+	for _, r := range out {
+		if !inCollection[r.Identifier] {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
+}
+
+func (rd *RecipeData) isAllRecipes(id string) bool {
+	all := rd.resp.AllRecipesCollection
+	return all != nil && all.Identifier == id
+}
+
+// SortRecipes sorts recipes in place. Manual order leaves the slice as is.
+// Ratings and creation dates sort highest/newest first, prep and cook times
+// sort quickest first, with unknown times last. Ties are broken by name.
+func SortRecipes(recipes []*pb.PBRecipe, order pb.PBRecipeCollectionSettings_SortOrder) {
+	byName := func(a, b *pb.PBRecipe) bool {
+		return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+	}
+	// Zero times mean the recipe doesn't specify one.
+	byTime := func(a, b int32) (less, ok bool) {
+		switch {
+		case a == b:
+			return false, false
+		case a == 0:
+			return false, true
+		case b == 0:
+			return true, true
+		default:
+			return a < b, true
+		}
+	}
+
+	var less func(a, b *pb.PBRecipe) bool
+	switch order {
+	case pb.PBRecipeCollectionSettings_AlphabeticalSortOrder:
+		less = byName
+	case pb.PBRecipeCollectionSettings_RatingSortOrder:
+		less = func(a, b *pb.PBRecipe) bool {
+			if a.Rating != b.Rating {
+				return a.Rating > b.Rating
+			}
+			return byName(a, b)
+		}
+	case pb.PBRecipeCollectionSettings_DateCreatedSortOrder:
+		less = func(a, b *pb.PBRecipe) bool {
+			if a.CreationTimestamp != b.CreationTimestamp {
+				return a.CreationTimestamp > b.CreationTimestamp
+			}
+			return byName(a, b)
+		}
+	case pb.PBRecipeCollectionSettings_PrepTimeSortOrder:
+		less = func(a, b *pb.PBRecipe) bool {
+			if l, ok := byTime(a.PrepTime, b.PrepTime); ok {
+				return l
+			}
+			return byName(a, b)
+		}
+	case pb.PBRecipeCollectionSettings_CookTimeSortOrder:
+		less = func(a, b *pb.PBRecipe) bool {
+			if l, ok := byTime(a.CookTime, b.CookTime); ok {
+				return l
+			}
+			return byName(a, b)
+		}
+	default:
+		return
+	}
+
+	sort.SliceStable(recipes, func(i, j int) bool {
+		return less(recipes[i], recipes[j])
+	})
+}
+
+// ParseRecipeSortOrder parses a sort order name, as used in the server's
+// query parameters, e.g. "rating" or "prep_time".
+func ParseRecipeSortOrder(s string) (pb.PBRecipeCollectionSettings_SortOrder, error) {
+	switch strings.ToLower(s) {
+	case "manual":
+		return pb.PBRecipeCollectionSettings_ManualSortOrder, nil
+	case "alphabetical", "name":
+		return pb.PBRecipeCollectionSettings_AlphabeticalSortOrder, nil
+	case "rating":
+		return pb.PBRecipeCollectionSettings_RatingSortOrder, nil
+	case "date_created", "created":
+		return pb.PBRecipeCollectionSettings_DateCreatedSortOrder, nil
+	case "prep_time":
+		return pb.PBRecipeCollectionSettings_PrepTimeSortOrder, nil
+	case "cook_time":
+		return pb.PBRecipeCollectionSettings_CookTimeSortOrder, nil
+	default:
+		return 0, fmt.Errorf("unknown sort order %q", s)
+	}
+}
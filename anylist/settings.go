@@ -0,0 +1,162 @@
+package anylist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bcspragu/anylist/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+// ListSettings returns the user's settings for the given list from a user
+// data response, as returned by Lists.
+func ListSettings(in *pb.PBUserDataResponse, listID string) (*pb.PBListSettings, bool) {
+	for _, s := range in.GetListSettingsResponse().GetSettings() {
+		if s.ListId == listID {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// ListSettings loads the user's settings for the given list.
+func (c *Client) ListSettings(ctx context.Context, listID string) (*pb.PBListSettings, error) {
+	resp, err := c.Lists(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user data: %w", err)
+	}
+	s, ok := ListSettings(resp, listID)
+	if !ok {
+		return nil, fmt.Errorf("no settings found for list %q", listID)
+	}
+	return s, nil
+}
+
+// UpdateListSettings saves the settings for a list. Settings should be based
+// on ones previously loaded with ListSettings, since the whole settings
+// message is replaced.
+func (c *Client) UpdateListSettings(ctx context.Context, settings *pb.PBListSettings) error {
+	if settings.Identifier == "" || settings.ListId == "" {
+		return errors.New("list settings must have an identifier and a list ID")
+	}
+	settings = proto.Clone(settings).(*pb.PBListSettings)
+	settings.Timestamp = Timestamp(time.Now())
+
+	req := &pb.PBListSettingsOperationList{
+		Operations: []*pb.PBListSettingsOperation{
+			{
+				Metadata:        c.metadata("saved-settings"),
+				UpdatedSettings: settings,
+			},
+		},
+	}
+	if err := c.postOperations(ctx, "/data/list-settings/update", req); err != nil {
+		return fmt.Errorf("failed to update list settings: %w", err)
+	}
+	return nil
+}
+
+// MobileAppSettings loads the user's app-wide settings, like their default
+// list and whether they prefer metric units.
+func (c *Client) MobileAppSettings(ctx context.Context) (*pb.PBMobileAppSettings, error) {
+	resp, err := c.Lists(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user data: %w", err)
+	}
+	s := resp.GetMobileAppSettingsResponse()
+	if s == nil {
+		return nil, errors.New("no mobile app settings found")
+	}
+	return s, nil
+}
+
+// UpdateMobileAppSettings saves the user's app-wide settings. Like
+// UpdateListSettings, the whole settings message is replaced, so settings
+// should be based on ones loaded with MobileAppSettings.
+func (c *Client) UpdateMobileAppSettings(ctx context.Context, settings *pb.PBMobileAppSettings) error {
+	if settings.Identifier == "" {
+		return errors.New("mobile app settings must have an identifier")
+	}
+	settings = proto.Clone(settings).(*pb.PBMobileAppSettings)
+	settings.Timestamp = Timestamp(time.Now())
+
+	req := &pb.PBMobileAppSettingsOperationList{
+		Operations: []*pb.PBMobileAppSettingsOperation{
+			{
+				Metadata:        c.metadata("saved-settings"),
+				UpdatedSettings: settings,
+			},
+		},
+	}
+	if err := c.postOperations(ctx, "/data/mobile-app-settings/update", req); err != nil {
+		return fmt.Errorf("failed to update mobile app settings: %w", err)
+	}
+	return nil
+}
+
+// SetDefaultList makes the given list the one the apps open to.
+func (c *Client) SetDefaultList(ctx context.Context, listID string) error {
+	settings, err := c.MobileAppSettings(ctx)
+	if err != nil {
+		return err
+	}
+	settings.DefaultListId = listID
+	return c.UpdateMobileAppSettings(ctx, settings)
+}
+
+// DefaultList returns the user's default list from a user data response, as
+// returned by Lists.
+func DefaultList(in *pb.PBUserDataResponse) (*pb.ShoppingList, bool) {
+	id := in.GetMobileAppSettingsResponse().GetDefaultListId()
+	if id == "" {
+		return nil, false
+	}
+	for _, l := range in.GetShoppingListsResponse().GetNewLists() {
+		if l.Identifier == id {
+			return l, true
+		}
+	}
+	return nil, false
+}
+
+// ListCategoryNames maps the identifiers of a list's categories to their
+// names. Categories are also indexed by their system category (e.g.
+// "dairy"), which older items use to refer to them.
+func ListCategoryNames(in *pb.PBUserDataResponse, listID string) map[string]string {
+	out := make(map[string]string)
+	for _, lr := range in.GetShoppingListsResponse().GetListResponses() {
+		if lr.ListId != listID {
+			continue
+		}
+		for _, cgr := range lr.CategoryGroupResponses {
+			for _, cat := range cgr.GetCategoryGroup().GetCategories() {
+				out[cat.Identifier] = cat.Name
+				if cat.SystemCategory == "" {
+					continue
+				}
+				if _, ok := out[cat.SystemCategory]; !ok {
+					out[cat.SystemCategory] = cat.Name
+				}
+			}
+		}
+	}
+	return out
+}
+
+// ItemCategory returns the name of the category an item is filed under, using
+// a map from ListCategoryNames. It's empty if the item isn't categorized.
+func ItemCategory(item *pb.ListItem, categories map[string]string) string {
+	for _, ca := range item.CategoryAssignments {
+		if name, ok := categories[ca.CategoryId]; ok {
+			return name
+		}
+	}
+	for _, id := range []string{item.CategoryMatchId, item.Category} {
+		if name, ok := categories[id]; ok {
+			return name
+		}
+	}
+	return ""
+}
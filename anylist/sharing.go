@@ -0,0 +1,105 @@
+package anylist
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bcspragu/anylist/pb"
+)
+
+// ShareError is returned when AnyList refuses to share a list, e.g. because
+// the email address doesn't belong to an AnyList account.
+type ShareError struct {
+	StatusCode int32
+	Title      string
+	Message    string
+}
+
+func (e *ShareError) Error() string {
+	switch {
+	case e.Title != "" && e.Message != "":
+		return fmt.Sprintf("%s: %s", e.Title, e.Message)
+	case e.Message != "":
+		return e.Message
+	case e.Title != "":
+		return e.Title
+	default:
+		return fmt.Sprintf("sharing failed with status code %d", e.StatusCode)
+	}
+}
+
+// ListMembers returns the users a list is shared with, including the user
+// themselves.
+func (c *Client) ListMembers(ctx context.Context, listID string) ([]*pb.PBEmailUserIDPair, error) {
+	resp, err := c.Lists(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load lists: %w", err)
+	}
+	for _, l := range resp.GetShoppingListsResponse().GetNewLists() {
+		if l.Identifier == listID {
+			return l.SharedUsers, nil
+		}
+	}
+	return nil, fmt.Errorf("no list with ID %q found", listID)
+}
+
+// ShareList shares a list with the AnyList user with the given email
+// address, returning the new member. If AnyList rejects the request, the
+// error is a *ShareError with AnyList's explanation.
+func (c *Client) ShareList(ctx context.Context, listID, email string) (*pb.PBEmailUserIDPair, error) {
+	req := &pb.PBListOperationList{
+		Operations: []*pb.PBListOperation{
+			{
+				Metadata:     c.metadata("share-list-with-user"),
+				ListId:       listID,
+				UpdatedValue: email,
+			},
+		},
+	}
+
+	var resp pb.PBShareListOperationResponse
+	if err := c.postOperationsWithResponse(ctx, "/data/shopping-lists/share-list", req, &resp); err != nil {
+		return nil, fmt.Errorf("failed to share list: %w", err)
+	}
+	if err := shareError(&resp); err != nil {
+		return nil, err
+	}
+	return resp.SharedUser, nil
+}
+
+// RemoveListMember stops sharing a list with the given user. If AnyList
+// rejects the request, the error is a *ShareError with AnyList's
+// explanation.
+func (c *Client) RemoveListMember(ctx context.Context, listID, userID string) error {
+	req := &pb.PBListOperationList{
+		Operations: []*pb.PBListOperation{
+			{
+				Metadata:     c.metadata("remove-shared-user"),
+				ListId:       listID,
+				UpdatedValue: userID,
+			},
+		},
+	}
+
+	var resp pb.PBShareListOperationResponse
+	if err := c.postOperationsWithResponse(ctx, "/data/shopping-lists/unshare-list", req, &resp); err != nil {
+		return fmt.Errorf("failed to remove list member: %w", err)
+	}
+	if err := shareError(&resp); err != nil {
+		return err
+	}
+	return nil
+}
+
+// shareError returns a *ShareError if the response indicates a failure. A
+// zero status code means success.
+func shareError(resp *pb.PBShareListOperationResponse) error {
+	if resp.StatusCode == 0 && resp.ErrorTitle == "" && resp.ErrorMessage == "" {
+		return nil
+	}
+	return &ShareError{
+		StatusCode: resp.StatusCode,
+		Title:      resp.ErrorTitle,
+		Message:    resp.ErrorMessage,
+	}
+}
@@ -0,0 +1,68 @@
+package anylist
+
+import (
+	"github.com/bcspragu/anylist/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+// DefaultListTheme approximates the theme AnyList uses for lists that haven't
+// picked one.
+var DefaultListTheme = &pb.PBListTheme{
+	Identifier:            "default",
+	Name:                  "Default",
+	BannerHexColor:        "4A8FD9",
+	BackgroundHexColor:    "FFFFFF",
+	ItemNameHexColor:      "000000",
+	ItemDetailsHexColor:   "8E8E93",
+	ControlHexColor:       "4A8FD9",
+	SeparatorHexColor:     "C8C7CC",
+	NavigationBarHexColor: "4A8FD9",
+	CellHexColor:          "FFFFFF",
+	TableHexColor:         "EFEFF4",
+	SelectionHexColor:     "D9D9D9",
+}
+
+// ListTheme returns the effective theme for a list with the given settings,
+// which may be nil. The list's custom theme is used if listThemeId selects it
+// (or is empty), with any colors it doesn't set taken from the default theme.
+//
+// The built-in themes that listThemeId can also refer to ship with the apps
+// rather than in the user's data, so we don't know their colors. Lists using
+// one of those fall back to the default theme.
+func ListTheme(settings *pb.PBListSettings) *pb.PBListTheme {
+	theme := proto.Clone(DefaultListTheme).(*pb.PBListTheme)
+
+	custom := settings.GetCustomTheme()
+	if custom == nil {
+		return theme
+	}
+	if id := settings.GetListThemeId(); id != "" && id != custom.Identifier {
+		// A built-in theme, which we fall back to the default for. The custom
+		// theme is left over from before the list switched to it.
+		return theme
+	}
+
+	theme.Identifier = custom.Identifier
+	overlay := func(dst *string, src string) {
+		if src != "" {
+			*dst = src
+		}
+	}
+	overlay(&theme.Name, custom.Name)
+	overlay(&theme.FontName, custom.FontName)
+	overlay(&theme.BannerHexColor, custom.BannerHexColor)
+	overlay(&theme.BackgroundHexColor, custom.BackgroundHexColor)
+	overlay(&theme.BackgroundTexture, custom.BackgroundTexture)
+	overlay(&theme.ItemNameHexColor, custom.ItemNameHexColor)
+	overlay(&theme.ItemDetailsHexColor, custom.ItemDetailsHexColor)
+	overlay(&theme.ControlHexColor, custom.ControlHexColor)
+	overlay(&theme.SeparatorHexColor, custom.SeparatorHexColor)
+	overlay(&theme.NavigationBarHexColor, custom.NavigationBarHexColor)
+	overlay(&theme.CellHexColor, custom.CellHexColor)
+	overlay(&theme.CellTexture, custom.CellTexture)
+	overlay(&theme.TableHexColor, custom.TableHexColor)
+	overlay(&theme.TableTexture, custom.TableTexture)
+	overlay(&theme.BackgroundImage, custom.BackgroundImage)
+	overlay(&theme.SelectionHexColor, custom.SelectionHexColor)
+	return theme
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiError is the body of every error response from the API.
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// writeJSON writes v as the JSON response body with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("failed to write response: %v", err)
+	}
+}
+
+// writeError responds with a JSON error body, like {"error": "item not found"}.
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, apiError{Error: msg})
+}
+
+// allowMethods responds with 405 Method Not Allowed and returns false if the
+// request's method isn't one of methods.
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, m := range methods {
+		if r.Method == m {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	return false
+}
+
+// etagPrefix distinguishes this run of the server's revisions from the last
+// one's, since revisions start over when the server restarts.
+var etagPrefix = strconv.FormatInt(time.Now().UnixNano(), 36)
+
+// notModified sets the ETag of a response built from the given revision of the
+// store, and responds with 304 Not Modified if the request's If-None-Match
+// header says the client already has it.
+func notModified(w http.ResponseWriter, r *http.Request, revision uint64) bool {
+	etag := fmt.Sprintf(`"%s-%d"`, etagPrefix, revision)
+	w.Header().Set("ETag", etag)
+
+	for _, match := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		match = strings.TrimPrefix(strings.TrimSpace(match), "W/")
+		if match == etag || match == "*" {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+// Command paprika moves recipes between AnyList and Paprika recipe archives
+// (.paprikarecipes files).
+//
+// Importing an archive that was previously imported (or exported from
+// AnyList) updates the existing recipes instead of creating duplicates.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/bcspragu/anylist/anylist"
+	"github.com/bcspragu/anylist/paprika"
+	"github.com/namsral/flag"
+	"go.mozilla.org/sops/v3/decrypt"
+)
+
+type SecretConfig struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+func main() {
+	if err := run(os.Args); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(args []string) error {
+	if len(args) == 0 {
+		return errors.New("args cannot be empty")
+	}
+
+	fs := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	var (
+		sopsConfigPath = fs.String("sops_encrypted_config", "secrets.enc.json", "A JSON-formatted configuration file with AnyList credentials, parseable by the SOPS tool (https://github.com/mozilla/sops).")
+		importPath     = fs.String("import", "", "Path to a .paprikarecipes archive to import into AnyList.")
+		exportPath     = fs.String("export", "", "Path to write a .paprikarecipes archive of every AnyList recipe to.")
+	)
+	if err := fs.Parse(args[1:]); err != nil {
+		return fmt.Errorf("failed to parse flags: %v", err)
+	}
+	if (*importPath == "") == (*exportPath == "") {
+		return errors.New("exactly one of --import or --export must be specified")
+	}
+
+	secCfg, err := decryptConfig(*sopsConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt secret config: %w", err)
+	}
+
+	ctx := context.Background()
+	c, err := anylist.New(ctx, secCfg.Email, secCfg.Password)
+	if err != nil {
+		return fmt.Errorf("failed to init anylist client: %w", err)
+	}
+
+	rd, err := c.RecipeData(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load recipes: %w", err)
+	}
+
+	if *exportPath != "" {
+		return export(rd, *exportPath)
+	}
+	return importArchive(ctx, c, rd, *importPath)
+}
+
+func export(rd *anylist.RecipeData, path string) error {
+	var recipes []*paprika.Recipe
+	for _, r := range rd.Recipes() {
+		recipes = append(recipes, paprika.FromPB(r))
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer f.Close()
+
+	if err := paprika.WriteArchive(f, recipes); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close archive: %w", err)
+	}
+
+	log.Printf("Exported %d recipes to %s", len(recipes), path)
+	return nil
+}
+
+func importArchive(ctx context.Context, c *anylist.Client, rd *anylist.RecipeData, path string) error {
+	recipes, err := paprika.OpenArchive(path)
+	if err != nil {
+		return fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	created, updated := paprika.Import(recipes, rd.Recipes())
+	for _, r := range created {
+		if _, err := c.CreateRecipe(ctx, rd.ID(), r); err != nil {
+			return fmt.Errorf("failed to create recipe %q: %w", r.Name, err)
+		}
+	}
+	for _, r := range updated {
+		if _, err := c.UpdateRecipe(ctx, rd.ID(), r); err != nil {
+			return fmt.Errorf("failed to update recipe %q: %w", r.Name, err)
+		}
+	}
+
+	log.Printf("Imported %d new recipes and updated %d existing recipes from %s", len(created), len(updated), path)
+	return nil
+}
+
+func decryptConfig(secPath string) (*SecretConfig, error) {
+	dat, err := ioutil.ReadFile(secPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret file: %w", err)
+	}
+	dec, err := decrypt.Data(dat, "json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secret file: %w", err)
+	}
+	var sc SecretConfig
+	if err := json.Unmarshal(dec, &sc); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal secret config: %w", err)
+	}
+	return &sc, nil
+}
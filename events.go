@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bcspragu/anylist/pb"
+	"github.com/bcspragu/anylist/store"
+)
+
+// ListDiff is the change to a list between two revisions of the store, sent as
+// a "diff" event by /api/events.
+type ListDiff struct {
+	ListID string `json:"list_id"`
+	// Name is set if the list was renamed.
+	Name    string   `json:"name,omitempty"`
+	Added   []Item   `json:"added,omitempty"`
+	Updated []Item   `json:"updated,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	// Deleted is true if the whole list was deleted.
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+const (
+	// pollInterval is how often we refresh from AnyList when nobody is
+	// watching for changes.
+	pollInterval = 10 * time.Minute
+	// livePollInterval is how often we refresh while someone is watching
+	// /api/events, so changes made in the AnyList apps show up quickly.
+	livePollInterval = 30 * time.Second
+)
+
+// pollForChanges refreshes the store periodically, more often while someone is
+// watching for changes. We can't use AnyList's listener for this yet, see
+// Client.Listen.
+func pollForChanges(ctx context.Context, st *store.Store) {
+	t := time.NewTicker(livePollInterval)
+	defer t.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if st.Subscribers() == 0 && time.Since(last) < pollInterval {
+				continue
+			}
+			last = time.Now()
+			if err := st.Refresh(ctx); err != nil {
+				log.Printf("failed to refresh lists: %v", err)
+			}
+		}
+	}
+}
+
+// keepAliveInterval is how often we send a comment on an otherwise idle event
+// stream, so proxies don't time it out.
+const keepAliveInterval = 30 * time.Second
+
+// handleEvents serves /api/events, a Server-Sent Events stream of changes to
+// lists, whether they're made through this server or picked up from AnyList.
+// The stream starts with a "snapshot" event for each list, and sends a "diff"
+// event with a ListDiff for each list that changes after that. It can be
+// limited to a single list with the list_id query parameter.
+func handleEvents(st *store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, http.MethodGet) {
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			writeError(w, http.StatusInternalServerError, "streaming isn't supported")
+			return
+		}
+		listID := r.URL.Query().Get("list_id")
+
+		// Subscribe before taking the first snapshot, so we can't miss a change
+		// in between.
+		updates, cancel := st.Subscribe()
+		defer cancel()
+
+		prev, rev := st.Snapshot()
+		if listID != "" {
+			if _, ok := listByID(prev.GetShoppingListsResponse().GetNewLists(), listID); !ok {
+				writeError(w, http.StatusNotFound, "list not found")
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+
+		for _, l := range prev.GetShoppingListsResponse().GetNewLists() {
+			if listID != "" && l.Identifier != listID {
+				continue
+			}
+			if err := writeEvent(w, "snapshot", rev, toList(prev, l)); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+
+		keepAlive := time.NewTicker(keepAliveInterval)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-keepAlive.C:
+				if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+				flusher.Flush()
+			case <-updates:
+				next, rev := st.Snapshot()
+				for _, d := range diffLists(prev, next) {
+					if listID != "" && d.ListID != listID {
+						continue
+					}
+					if err := writeEvent(w, "diff", rev, d); err != nil {
+						return
+					}
+				}
+				flusher.Flush()
+				prev = next
+			}
+		}
+	}
+}
+
+// writeEvent writes a single Server-Sent Event with v as its JSON data.
+func writeEvent(w io.Writer, event string, revision uint64, v interface{}) error {
+	dat, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("failed to marshal %s event: %v", event, err)
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\nid: %s-%d\ndata: %s\n\n", event, etagPrefix, revision, dat)
+	return err
+}
+
+// diffLists returns the changes to each list from prev to next, as they'd be
+// seen through the API, so e.g. checking an item on a list that hides
+// completed items removes it.
+func diffLists(prev, next *pb.PBUserDataResponse) []ListDiff {
+	prevLists := prev.GetShoppingListsResponse().GetNewLists()
+	nextLists := next.GetShoppingListsResponse().GetNewLists()
+
+	var out []ListDiff
+	for _, nl := range nextLists {
+		pl, ok := listByID(prevLists, nl.Identifier)
+		if ok && pl == nl {
+			// Lists that weren't updated are shared between snapshots.
+			continue
+		}
+
+		after := toList(next, nl)
+		var before *List
+		if ok {
+			before = toList(prev, pl)
+		} else {
+			before = &List{ID: nl.Identifier}
+		}
+		if d, changed := diffList(before, after); changed {
+			out = append(out, d)
+		}
+	}
+	for _, pl := range prevLists {
+		if _, ok := listByID(nextLists, pl.Identifier); !ok {
+			out = append(out, ListDiff{ListID: pl.Identifier, Deleted: true})
+		}
+	}
+	return out
+}
+
+func diffList(before, after *List) (ListDiff, bool) {
+	d := ListDiff{ListID: after.ID}
+	if before.Name != after.Name {
+		d.Name = after.Name
+	}
+
+	old := make(map[string]Item)
+	for _, item := range before.Items {
+		old[item.ID] = item
+	}
+	for _, item := range after.Items {
+		prev, ok := old[item.ID]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, item)
+		case prev != item:
+			d.Updated = append(d.Updated, item)
+		}
+		delete(old, item.ID)
+	}
+	for _, item := range before.Items {
+		if _, ok := old[item.ID]; ok {
+			d.Removed = append(d.Removed, item.ID)
+		}
+	}
+
+	changed := d.Name != "" || len(d.Added) > 0 || len(d.Updated) > 0 || len(d.Removed) > 0
+	return d, changed
+}
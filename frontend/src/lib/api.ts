@@ -1,25 +1,184 @@
-const postData = (path: string, data: FormData): Promise<Response> => {
-	return fetch(path, {
+import type { Item } from '$lib/Checkbox.svelte';
+
+export interface List {
+	id: string;
+	name: string;
+	items: Item[];
+	theme?: Theme;
+}
+
+// APIError is thrown when the API responds with an error. For conflicts
+// (status 409), body.item is the item as it is now on the server.
+export class APIError extends Error {
+	status: number;
+	body: { error?: string; item?: Item };
+
+	constructor(status: number, body: { error?: string; item?: Item }) {
+		super(body.error ?? `request failed with status ${status}`);
+		this.status = status;
+		this.body = body;
+	}
+}
+
+// postData posts a form to the API and returns the decoded JSON response,
+// throwing an APIError if the request failed.
+const postData = async <T>(path: string, data: FormData): Promise<T> => {
+	const res = await fetch(path, {
 		method: 'POST',
 		body: data
 	});
+	const body = await res.json();
+	if (!res.ok) {
+		throw new APIError(res.status, body);
+	}
+	return body as T;
 };
 
-export const addItem = (itemName: string): Promise<Response> => {
+export const addItem = (itemName: string): Promise<Item> => {
 	const formData = new FormData();
 	formData.append('item_name', itemName);
 	return postData('/api/add', formData);
 };
 
-export const removeItem = (itemID: string): Promise<Response> => {
+// removeItem removes an item. If version is given, the item is only removed if
+// nobody has changed it since.
+export const removeItem = (itemID: string, version?: string): Promise<List> => {
 	const formData = new FormData();
 	formData.append('item_id', itemID);
+	if (version) {
+		formData.append('version', version);
+	}
 	return postData('/api/remove', formData);
 };
 
-export const checkItem = (itemID: string, checked: boolean): Promise<Response> => {
+// checkItem checks or unchecks an item. If version is given, the item is only
+// changed if nobody has changed it since.
+export const checkItem = (itemID: string, checked: boolean, version?: string): Promise<Item> => {
 	const formData = new FormData();
 	formData.append('item_id', itemID);
 	formData.append('checked', checked ? 'true' : 'false');
+	if (version) {
+		formData.append('version', version);
+	}
 	return postData('/api/check', formData);
 };
+
+// clearChecked removes every checked item from a list, and returns the list.
+export const clearChecked = (listID: string): Promise<List> => {
+	return postData(`/api/lists/${encodeURIComponent(listID)}/clear-checked`, new FormData());
+};
+
+// checkAll checks every item on a list, and returns the list.
+export const checkAll = (listID: string): Promise<List> => {
+	return postData(`/api/lists/${encodeURIComponent(listID)}/check-all`, new FormData());
+};
+
+// uncheckAll unchecks every item on a list, and returns the list.
+export const uncheckAll = (listID: string): Promise<List> => {
+	return postData(`/api/lists/${encodeURIComponent(listID)}/uncheck-all`, new FormData());
+};
+
+// undo undoes the most recent change this browser made to a list, and returns
+// the list as it is afterwards.
+export const undo = (): Promise<List> => {
+	return postData('/api/undo', new FormData());
+};
+
+export interface Suggestion {
+	name: string;
+	category?: string;
+}
+
+export const suggestItems = (query: string): Promise<Suggestion[]> => {
+	const params = new URLSearchParams({ q: query });
+	return fetch(`/api/suggest?${params}`).then((res) => res.json());
+};
+
+export interface Theme {
+	id: string;
+	name: string;
+	font?: string;
+	banner_color: string;
+	banner_text_color?: string;
+	navigation_bar_color: string;
+	background_color: string;
+	table_color: string;
+	cell_color: string;
+	separator_color: string;
+	selection_color: string;
+	control_color: string;
+	item_name_color: string;
+	item_details_color: string;
+}
+
+// themeStyle turns a list theme into CSS custom properties, for use in a style
+// attribute.
+export const themeStyle = (theme?: Theme): string => {
+	if (!theme) {
+		return '';
+	}
+	const props: [string, string | undefined][] = [
+		['banner-color', theme.banner_color],
+		['banner-text-color', theme.banner_text_color],
+		['background-color', theme.background_color],
+		['cell-color', theme.cell_color],
+		['separator-color', theme.separator_color],
+		['control-color', theme.control_color],
+		['item-name-color', theme.item_name_color],
+		['item-details-color', theme.item_details_color]
+	];
+	return props
+		.filter(([, value]) => value)
+		.map(([name, value]) => `--list-${name}: ${value};`)
+		.join(' ');
+};
+
+export interface ListDiff {
+	list_id: string;
+	name?: string;
+	added?: Item[];
+	updated?: Item[];
+	removed?: string[];
+	deleted?: boolean;
+}
+
+// applyDiff returns the list with the changes from a diff event applied.
+export const applyDiff = (list: List, diff: ListDiff): List => {
+	const changed = new Map<string, Item>();
+	for (const item of [...(diff.added ?? []), ...(diff.updated ?? [])]) {
+		changed.set(item.id, item);
+	}
+	const removed = new Set(diff.removed ?? []);
+	const items = list.items
+		.filter((i) => !removed.has(i.id))
+		.map((i) => {
+			const item = changed.get(i.id) ?? i;
+			changed.delete(i.id);
+			return item;
+		});
+	return {
+		...list,
+		name: diff.name ?? list.name,
+		items: [...items, ...changed.values()]
+	};
+};
+
+// watchList calls onChange with the latest version of a list whenever it
+// changes on the server. It returns a function that stops watching.
+export const watchList = (listID: string, onChange: (list: List) => void): (() => void) => {
+	const params = new URLSearchParams({ list_id: listID });
+	const source = new EventSource(`/api/events?${params}`);
+	let current: List | undefined;
+	source.addEventListener('snapshot', (e) => {
+		current = JSON.parse((e as MessageEvent).data);
+		onChange(current!);
+	});
+	source.addEventListener('diff', (e) => {
+		if (!current) {
+			return;
+		}
+		current = applyDiff(current, JSON.parse((e as MessageEvent).data));
+		onChange(current);
+	});
+	return () => source.close();
+};
@@ -0,0 +1,126 @@
+// Package ics writes iCalendar (RFC 5545) feeds of all-day events, which any
+// calendar app can subscribe to.
+package ics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Calendar is a named collection of events.
+type Calendar struct {
+	Name   string
+	Events []Event
+}
+
+// Event is an all-day event.
+type Event struct {
+	// UID must be globally unique and stable across feed refreshes, so
+	// calendar apps can track changes to the event.
+	UID         string
+	Date        time.Time
+	Summary     string
+	Description string
+	URL         string
+	Categories  []string
+}
+
+const prodID = "-//bcspragu//anylist//EN"
+
+// Encode writes the calendar in iCalendar format. now is used as the
+// timestamp for every event.
+func Encode(w io.Writer, cal *Calendar, now time.Time) error {
+	bw := bufio.NewWriter(w)
+	lw := &lineWriter{w: bw}
+
+	lw.line("BEGIN:VCALENDAR")
+	lw.line("VERSION:2.0")
+	lw.line("PRODID:" + prodID)
+	lw.line("CALSCALE:GREGORIAN")
+	lw.line("METHOD:PUBLISH")
+	if cal.Name != "" {
+		lw.line("X-WR-CALNAME:" + escape(cal.Name))
+	}
+
+	stamp := now.UTC().Format("20060102T150405Z")
+	for _, e := range cal.Events {
+		lw.line("BEGIN:VEVENT")
+		lw.line("UID:" + escape(e.UID))
+		lw.line("DTSTAMP:" + stamp)
+		lw.line("DTSTART;VALUE=DATE:" + e.Date.Format("20060102"))
+		lw.line("DTEND;VALUE=DATE:" + e.Date.AddDate(0, 0, 1).Format("20060102"))
+		lw.line("SUMMARY:" + escape(e.Summary))
+		if e.Description != "" {
+			lw.line("DESCRIPTION:" + escape(e.Description))
+		}
+		if e.URL != "" {
+			lw.line("URL:" + e.URL)
+		}
+		if len(e.Categories) > 0 {
+			var cats []string
+			for _, c := range e.Categories {
+				cats = append(cats, escape(c))
+			}
+			lw.line("CATEGORIES:" + strings.Join(cats, ","))
+		}
+		// Meals shouldn't block out the whole day.
+		lw.line("TRANSP:TRANSPARENT")
+		lw.line("END:VEVENT")
+	}
+	lw.line("END:VCALENDAR")
+
+	if lw.err != nil {
+		return fmt.Errorf("failed to write calendar: %w", lw.err)
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to flush calendar: %w", err)
+	}
+	return nil
+}
+
+var escaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+	"\r", `\n`,
+)
+
+// escape escapes a TEXT value.
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// lineWriter writes CRLF-terminated content lines, folding them at 75 octets
+// as the spec requires. It holds on to the first error it encounters.
+type lineWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (lw *lineWriter) line(s string) {
+	if lw.err != nil {
+		return
+	}
+	const maxLen = 75
+
+	var b strings.Builder
+	n := 0
+	for _, r := range s {
+		l := len(string(r))
+		// Continuation lines start with a space, which counts towards the
+		// limit.
+		if n+l > maxLen {
+			b.WriteString("\r\n ")
+			n = 1
+		}
+		b.WriteRune(r)
+		n += l
+	}
+	b.WriteString("\r\n")
+	_, lw.err = io.WriteString(lw.w, b.String())
+}
@@ -0,0 +1,92 @@
+package ingredient
+
+import "strings"
+
+// Add combines two ingredients with the same name into one, summing their
+// quantities. It reports false if the quantities can't be summed, e.g. "1
+// cup" and "1 can". Ingredients without a quantity (like "salt, to taste")
+// can be added to anything.
+func (i Ingredient) Add(o Ingredient) (Ingredient, bool) {
+	out := i
+	out.Note = joinNotes(i.Note, o.Note)
+
+	switch {
+	case o.Quantity == nil:
+		return out, true
+	case i.Quantity == nil:
+		out.Quantity, out.Unit, out.abbrev = o.Quantity, o.Unit, o.abbrev
+		return out, true
+	}
+
+	switch {
+	case i.Unit == o.Unit:
+		q := Quantity{Min: i.Quantity.Min + o.Quantity.Min, Max: i.Quantity.Max + o.Quantity.Max}
+		out.Quantity = &q
+		return out.Normalize(), true
+	case i.Unit != nil && o.Unit != nil && i.Unit.dimension != count && i.Unit.dimension == o.Unit.dimension:
+		// Convert the other quantity to our unit, then pick the best unit for
+		// the total.
+		f := o.Unit.base / i.Unit.base
+		q := Quantity{Min: i.Quantity.Min + o.Quantity.Min*f, Max: i.Quantity.Max + o.Quantity.Max*f}
+		out.Quantity = &q
+		return out.Normalize(), true
+	default:
+		return i, false
+	}
+}
+
+// Combine merges ingredients with the same name, summing quantities where
+// their units are compatible. Ingredients with the same name but
+// incompatible units are kept separate, and adjacent. Otherwise, ingredients
+// are returned in the order they first appear.
+func Combine(ings []Ingredient) []Ingredient {
+	var (
+		order  []string
+		groups = make(map[string][]Ingredient)
+	)
+	for _, ing := range ings {
+		key := Key(ing.Name)
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+
+		merged := false
+		for j, existing := range groups[key] {
+			if sum, ok := existing.Add(ing); ok {
+				groups[key][j] = sum
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			groups[key] = append(groups[key], ing)
+		}
+	}
+
+	var out []Ingredient
+	for _, key := range order {
+		out = append(out, groups[key]...)
+	}
+	return out
+}
+
+// Key returns a normalized form of an ingredient name, for deciding if two
+// ingredients are the same thing, e.g. "Eggs" and "egg".
+func Key(name string) string {
+	key := strings.Join(strings.Fields(strings.ToLower(name)), " ")
+	if len(key) > 3 && strings.HasSuffix(key, "s") && !strings.HasSuffix(key, "ss") {
+		key = strings.TrimSuffix(key, "s")
+	}
+	return key
+}
+
+func joinNotes(a, b string) string {
+	switch {
+	case a == "" || strings.EqualFold(a, b):
+		return b
+	case b == "":
+		return a
+	default:
+		return a + "; " + b
+	}
+}
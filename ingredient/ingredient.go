@@ -0,0 +1,328 @@
+// Package ingredient parses recipe ingredient lines like "1 1/2 cups flour,
+// sifted" into their quantity, unit, name and note, and can scale, convert
+// and re-render them.
+package ingredient
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bcspragu/anylist/pb"
+)
+
+// Quantity is an amount of an ingredient. For ranges like "2-3", Min and Max
+// differ, otherwise they're the same.
+type Quantity struct {
+	Min float64
+	Max float64
+}
+
+func (q Quantity) isRange() bool {
+	return q.Min != q.Max
+}
+
+func (q Quantity) scale(f float64) Quantity {
+	return Quantity{Min: q.Min * f, Max: q.Max * f}
+}
+
+// Ingredient is a parsed ingredient line.
+type Ingredient struct {
+	// Quantity is nil if the ingredient doesn't have one, e.g. "salt, to
+	// taste".
+	Quantity *Quantity
+	// Unit is nil if the ingredient doesn't have one, e.g. "2 eggs".
+	Unit *Unit
+	Name string
+	Note string
+
+	// abbrev is whether the unit should be rendered in its abbreviated form.
+	abbrev bool
+}
+
+var (
+	fractionReplacer = strings.NewReplacer(
+		"½", " 1/2", "⅓", " 1/3", "⅔", " 2/3", "¼", " 1/4", "¾", " 3/4",
+		"⅕", " 1/5", "⅖", " 2/5", "⅗", " 3/5", "⅘", " 4/5", "⅙", " 1/6",
+		"⅚", " 5/6", "⅐", " 1/7", "⅛", " 1/8", "⅜", " 3/8", "⅝", " 5/8",
+		"⅞", " 7/8", "⅑", " 1/9", "⅒", " 1/10",
+		// Fraction slash
+		"⁄", "/",
+		// En and em dashes, which show up in ranges.
+		"–", "-", "—", "-",
+	)
+
+	number     = `\d+\s+\d+/\d+|\d+/\d+|\d+(?:\.\d+)?|\.\d+`
+	quantityRE = regexp.MustCompile(`^(` + number + `)(?:\s*(?:-|to)\s*(` + number + `))?`)
+)
+
+// Parse parses an ingredient line. It never fails, anything it can't make
+// sense of ends up in the ingredient's name.
+func Parse(s string) Ingredient {
+	s = strings.TrimSpace(fractionReplacer.Replace(s))
+
+	var ing Ingredient
+	rest := s
+	if q, n, ok := parseQuantity(s); ok {
+		ing.Quantity = &q
+		rest = strings.TrimSpace(s[n:])
+		if a, n, ok := parseUnit(rest); ok {
+			ing.Unit = a.unit
+			ing.abbrev = a.abbrev
+			rest = strings.TrimSpace(rest[n:])
+			rest = strings.TrimPrefix(rest, "of ")
+		}
+	}
+
+	name, note, _ := strings.Cut(rest, ",")
+	ing.Name = strings.TrimSpace(name)
+	ing.Note = strings.TrimSpace(note)
+	return ing
+}
+
+// parseQuantity parses a quantity at the start of s, returning the number of
+// bytes consumed.
+func parseQuantity(s string) (Quantity, int, bool) {
+	m := quantityRE.FindStringSubmatchIndex(s)
+	if m == nil {
+		return Quantity{}, 0, false
+	}
+	min, ok := parseNumber(s[m[2]:m[3]])
+	if !ok {
+		return Quantity{}, 0, false
+	}
+	max := min
+	if m[4] >= 0 {
+		if max, ok = parseNumber(s[m[4]:m[5]]); !ok {
+			return Quantity{}, 0, false
+		}
+	}
+	return Quantity{Min: min, Max: max}, m[1], true
+}
+
+func parseNumber(s string) (float64, bool) {
+	var total float64
+	for _, f := range strings.Fields(s) {
+		if num, den, ok := strings.Cut(f, "/"); ok {
+			n, err := strconv.ParseFloat(num, 64)
+			if err != nil {
+				return 0, false
+			}
+			d, err := strconv.ParseFloat(den, 64)
+			if err != nil || d == 0 {
+				return 0, false
+			}
+			total += n / d
+			continue
+		}
+		n, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return 0, false
+		}
+		total += n
+	}
+	return total, true
+}
+
+// parseUnit parses a unit at the start of s, returning the number of bytes
+// consumed. Two word units like "fl oz" are checked before single words.
+func parseUnit(s string) (unitAlias, int, bool) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return unitAlias{}, 0, false
+	}
+	end := strings.Index(s, fields[0]) + len(fields[0])
+	if len(fields) > 1 {
+		if a, ok := lookupUnit(fields[0] + " " + fields[1]); ok {
+			return a, end + strings.Index(s[end:], fields[1]) + len(fields[1]), true
+		}
+	}
+	if a, ok := lookupUnit(fields[0]); ok {
+		return a, end, true
+	}
+	return unitAlias{}, 0, false
+}
+
+// FromPB converts an AnyList ingredient. If the ingredient has a name, its
+// quantity, name and note fields are used, otherwise the raw ingredient line
+// is parsed.
+func FromPB(in *pb.PBIngredient) Ingredient {
+	if in.GetName() == "" {
+		return Parse(in.GetRawIngredient())
+	}
+
+	ing := Ingredient{Name: in.Name, Note: in.Note}
+	q := strings.TrimSpace(fractionReplacer.Replace(in.Quantity))
+	if qty, n, ok := parseQuantity(q); ok {
+		ing.Quantity = &qty
+		rest := strings.TrimSpace(q[n:])
+		if a, _, ok := parseUnit(rest); ok {
+			ing.Unit = a.unit
+			ing.abbrev = a.abbrev
+		}
+	}
+	return ing
+}
+
+// ToPB converts the ingredient to AnyList's representation.
+func (i Ingredient) ToPB() *pb.PBIngredient {
+	return &pb.PBIngredient{
+		RawIngredient: i.String(),
+		Name:          i.Name,
+		Quantity:      i.QuantityString(),
+		Note:          i.Note,
+	}
+}
+
+// Scale returns the ingredient with its quantity multiplied by f.
+func (i Ingredient) Scale(f float64) Ingredient {
+	if i.Quantity == nil || f == 1 || f <= 0 {
+		return i
+	}
+	q := i.Quantity.scale(f)
+	i.Quantity = &q
+	return i
+}
+
+// Convert returns the ingredient with its quantity expressed in the given
+// system of measurement, picking whichever unit reads best. Ingredients
+// without a quantity or a measurable unit are returned as is, as are all
+// ingredients if sys is NoSystem. Converting to the ingredient's own system
+// normalizes it, e.g. 6 teaspoons becomes 2 tablespoons.
+func (i Ingredient) Convert(sys System) Ingredient {
+	if i.Quantity == nil || i.Unit == nil || i.Unit.dimension == count || sys == NoSystem {
+		return i
+	}
+
+	amount := i.Quantity.Min * i.Unit.base
+	u := bestUnit(amount, i.Unit.dimension, sys)
+	if u == nil || u == i.Unit {
+		return i
+	}
+	q := i.Quantity.scale(i.Unit.base / u.base)
+	i.Quantity = &q
+	i.Unit = u
+	// Metric units are almost always written abbreviated.
+	if u.System == Metric {
+		i.abbrev = true
+	}
+	return i
+}
+
+// Normalize converts the ingredient to the best unit in its own system.
+func (i Ingredient) Normalize() Ingredient {
+	if i.Unit == nil {
+		return i
+	}
+	return i.Convert(i.Unit.System)
+}
+
+// QuantityString renders the quantity and unit, e.g. "1 1/2 cups". It's
+// empty if the ingredient doesn't have a quantity.
+func (i Ingredient) QuantityString() string {
+	if i.Quantity == nil {
+		return ""
+	}
+	metric := i.Unit != nil && i.Unit.System == Metric
+
+	out := formatNumber(i.Quantity.Min, metric)
+	if i.Quantity.isRange() {
+		out += "-" + formatNumber(i.Quantity.Max, metric)
+	}
+	if i.Unit == nil {
+		return out
+	}
+
+	unit := i.Unit.Name
+	switch {
+	case i.abbrev && i.Unit.Abbrev != "":
+		unit = i.Unit.Abbrev
+	case i.Quantity.Max > 1:
+		unit = i.Unit.Plural
+	}
+	return out + " " + unit
+}
+
+// String renders the full ingredient line, e.g. "1 1/2 cups flour, sifted".
+func (i Ingredient) String() string {
+	var parts []string
+	if q := i.QuantityString(); q != "" {
+		parts = append(parts, q)
+	}
+	if i.Name != "" {
+		parts = append(parts, i.Name)
+	}
+	out := strings.Join(parts, " ")
+	if i.Note != "" {
+		out += ", " + i.Note
+	}
+	return out
+}
+
+// ScaleQuantity scales a quantity string like "2 cups" or "1 1/2 tbsp",
+// returning it as is if it doesn't start with a number or f is 1.
+func ScaleQuantity(q string, f float64) string {
+	if f == 1 {
+		return q
+	}
+	ing := Parse(q)
+	if ing.Quantity == nil {
+		return q
+	}
+	return ing.Scale(f).String()
+}
+
+// SystemFor returns the system of measurement the user prefers, according to
+// their app settings.
+func SystemFor(settings *pb.PBMobileAppSettings) System {
+	if settings.GetShouldUseMetricUnits() {
+		return Metric
+	}
+	return Imperial
+}
+
+var fractions = []struct {
+	val float64
+	str string
+}{
+	{1.0 / 8, "1/8"},
+	{1.0 / 4, "1/4"},
+	{1.0 / 3, "1/3"},
+	{3.0 / 8, "3/8"},
+	{1.0 / 2, "1/2"},
+	{5.0 / 8, "5/8"},
+	{2.0 / 3, "2/3"},
+	{3.0 / 4, "3/4"},
+	{7.0 / 8, "7/8"},
+}
+
+// formatNumber renders a number the way a recipe would, as a mixed fraction
+// for imperial and unitless quantities, or a decimal for metric ones.
+func formatNumber(f float64, metric bool) string {
+	if metric {
+		if f >= 10 {
+			return strconv.FormatFloat(math.Round(f), 'f', -1, 64)
+		}
+		return strconv.FormatFloat(math.Round(f*10)/10, 'f', -1, 64)
+	}
+
+	const tolerance = 0.02
+	whole, frac := math.Modf(f)
+	if frac < tolerance {
+		return strconv.FormatFloat(whole, 'f', -1, 64)
+	}
+	if frac > 1-tolerance {
+		return strconv.FormatFloat(whole+1, 'f', -1, 64)
+	}
+	for _, fr := range fractions {
+		if math.Abs(frac-fr.val) > tolerance {
+			continue
+		}
+		if whole == 0 {
+			return fr.str
+		}
+		return strconv.FormatFloat(whole, 'f', -1, 64) + " " + fr.str
+	}
+	return strconv.FormatFloat(math.Round(f*100)/100, 'f', -1, 64)
+}
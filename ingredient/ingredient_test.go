@@ -0,0 +1,175 @@
+package ingredient
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in       string
+		min, max float64
+		noQty    bool
+		unit     *Unit
+		name     string
+		note     string
+	}{
+		{in: "2 cups flour", min: 2, max: 2, unit: cup, name: "flour"},
+		{in: "1 1/2 cups flour, sifted", min: 1.5, max: 1.5, unit: cup, name: "flour", note: "sifted"},
+		{in: "1/2 tsp salt", min: 0.5, max: 0.5, unit: teaspoon, name: "salt"},
+		{in: "1.5 lbs chicken", min: 1.5, max: 1.5, unit: pound, name: "chicken"},
+		{in: "½ cup milk", min: 0.5, max: 0.5, unit: cup, name: "milk"},
+		{in: "1½ cups sugar", min: 1.5, max: 1.5, unit: cup, name: "sugar"},
+		{in: "1 ½ cups sugar", min: 1.5, max: 1.5, unit: cup, name: "sugar"},
+		{in: "1⁄4 cup oil", min: 0.25, max: 0.25, unit: cup, name: "oil"},
+		{in: "2-3 cloves garlic, minced", min: 2, max: 3, unit: clove, name: "garlic", note: "minced"},
+		{in: "2–3 tbsp butter", min: 2, max: 3, unit: tablespoon, name: "butter"},
+		{in: "1 to 2 cups water", min: 1, max: 2, unit: cup, name: "water"},
+		{in: "1 T sugar", min: 1, max: 1, unit: tablespoon, name: "sugar"},
+		{in: "1 t sugar", min: 1, max: 1, unit: teaspoon, name: "sugar"},
+		{in: "2 Tbsp. butter", min: 2, max: 2, unit: tablespoon, name: "butter"},
+		{in: "8 fl oz cream", min: 8, max: 8, unit: fluidOunce, name: "cream"},
+		{in: "1 cup of rice", min: 1, max: 1, unit: cup, name: "rice"},
+		{in: "3 eggs", min: 3, max: 3, name: "eggs"},
+		{in: "salt, to taste", noQty: true, name: "salt", note: "to taste"},
+	}
+	for _, test := range tests {
+		got := Parse(test.in)
+		if test.noQty {
+			if got.Quantity != nil {
+				t.Errorf("Parse(%q) quantity = %+v, want none", test.in, *got.Quantity)
+			}
+		} else if got.Quantity == nil {
+			t.Errorf("Parse(%q) has no quantity, want %g-%g", test.in, test.min, test.max)
+		} else if got.Quantity.Min != test.min || got.Quantity.Max != test.max {
+			t.Errorf("Parse(%q) quantity = %+v, want %g-%g", test.in, *got.Quantity, test.min, test.max)
+		}
+		if got.Unit != test.unit {
+			t.Errorf("Parse(%q) unit = %v, want %v", test.in, got.Unit, test.unit)
+		}
+		if got.Name != test.name {
+			t.Errorf("Parse(%q) name = %q, want %q", test.in, got.Name, test.name)
+		}
+		if got.Note != test.note {
+			t.Errorf("Parse(%q) note = %q, want %q", test.in, got.Note, test.note)
+		}
+	}
+}
+
+func TestScale(t *testing.T) {
+	tests := []struct {
+		in   string
+		f    float64
+		want string
+	}{
+		{"2 cups flour", 2, "4 cups flour"},
+		{"1 1/2 cups flour", 2, "3 cups flour"},
+		{"1 cup milk", 0.5, "1/2 cup milk"},
+		{"6 eggs", 0.5, "3 eggs"},
+		{"2-3 tbsp butter", 2, "4-6 tbsp butter"},
+		{"½ tsp salt", 3, "1 1/2 tsp salt"},
+		{"salt, to taste", 2, "salt, to taste"},
+		{"2 cups flour", 1, "2 cups flour"},
+	}
+	for _, test := range tests {
+		if got := Parse(test.in).Scale(test.f).String(); got != test.want {
+			t.Errorf("Parse(%q).Scale(%g) = %q, want %q", test.in, test.f, got, test.want)
+		}
+	}
+}
+
+func TestScaleQuantity(t *testing.T) {
+	tests := []struct {
+		in   string
+		f    float64
+		want string
+	}{
+		// Unscaled quantities are left exactly as written.
+		{"2 Tbsp", 1, "2 Tbsp"},
+		{"1.5 cups", 1, "1.5 cups"},
+		{"2 Tbsp", 2, "4 tbsp"},
+		{"1.5 cups", 2, "3 cups"},
+		{"a pinch", 2, "a pinch"},
+	}
+	for _, test := range tests {
+		if got := ScaleQuantity(test.in, test.f); got != test.want {
+			t.Errorf("ScaleQuantity(%q, %g) = %q, want %q", test.in, test.f, got, test.want)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		in   string
+		sys  System
+		want string
+	}{
+		{"1 cup milk", Metric, "237 ml milk"},
+		{"2 cups milk", Metric, "473 ml milk"},
+		{"4 cups milk", Metric, "946 ml milk"},
+		{"5 cups milk", Metric, "1.2 l milk"},
+		{"500 g flour", Imperial, "1.1 lb flour"},
+		{"250 ml water", Imperial, "1.06 cups water"},
+		{"6 tsp sugar", Imperial, "2 tbsp sugar"},
+		{"3 eggs", Metric, "3 eggs"},
+		{"2 cloves garlic", Metric, "2 cloves garlic"},
+		{"1 cup milk", NoSystem, "1 cup milk"},
+	}
+	for _, test := range tests {
+		if got := Parse(test.in).Convert(test.sys).String(); got != test.want {
+			t.Errorf("Parse(%q).Convert(%d) = %q, want %q", test.in, test.sys, got, test.want)
+		}
+	}
+}
+
+func TestCombine(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []string
+		want []string
+	}{
+		{
+			name: "same unit",
+			in:   []string{"1 cup flour", "2 cups flour"},
+			want: []string{"3 cups flour"},
+		},
+		{
+			name: "compatible units",
+			in:   []string{"1 tbsp butter", "3 tsp butter"},
+			want: []string{"2 tbsp butter"},
+		},
+		{
+			name: "plural names",
+			in:   []string{"2 eggs", "1 egg"},
+			want: []string{"3 eggs"},
+		},
+		{
+			name: "incompatible units stay separate and adjacent",
+			in:   []string{"1 cup tomatoes", "2 onions", "1 can tomatoes"},
+			want: []string{"1 cup tomatoes", "1 can tomatoes", "2 onions"},
+		},
+		{
+			name: "no quantity",
+			in:   []string{"salt, to taste", "1 tsp salt"},
+			want: []string{"1 tsp salt, to taste"},
+		},
+		{
+			name: "ranges",
+			in:   []string{"1-2 cups stock", "1 cup stock"},
+			want: []string{"2-3 cups stock"},
+		},
+	}
+	for _, test := range tests {
+		var ings []Ingredient
+		for _, s := range test.in {
+			ings = append(ings, Parse(s))
+		}
+		got := Combine(ings)
+		if len(got) != len(test.want) {
+			t.Errorf("%s: Combine(%q) returned %d ingredients, want %d: %v", test.name, test.in, len(got), len(test.want), got)
+			continue
+		}
+		for i, ing := range got {
+			if ing.String() != test.want[i] {
+				t.Errorf("%s: Combine(%q)[%d] = %q, want %q", test.name, test.in, i, ing.String(), test.want[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package ingredient
+
+import "strings"
+
+// System is a system of measurement.
+type System int
+
+const (
+	// NoSystem is used for units that don't belong to either system, like
+	// "clove" or "can", and for leaving units as they are when converting.
+	NoSystem System = iota
+	Metric
+	Imperial
+)
+
+type dimension int
+
+const (
+	count dimension = iota
+	volume
+	mass
+)
+
+// Unit is a unit of measure for an ingredient.
+type Unit struct {
+	Name   string
+	Plural string
+	// Abbrev is the short form of the unit, e.g. "tbsp", which is used when
+	// the ingredient was written with an abbreviation. Empty if the unit has
+	// no common abbreviation.
+	Abbrev string
+	System System
+
+	dimension dimension
+	// base is the size of one of this unit in milliliters for volumes, or
+	// grams for masses.
+	base float64
+	// min is the smallest amount of this unit we'll choose when picking a
+	// unit to display a quantity in, e.g. we'll use 1/4 cup, but not 1/8 cup.
+	// Zero means the unit is never picked automatically.
+	min float64
+}
+
+var (
+	teaspoon   = &Unit{Name: "teaspoon", Plural: "teaspoons", Abbrev: "tsp", System: Imperial, dimension: volume, base: 4.92892, min: 1.0 / 8}
+	tablespoon = &Unit{Name: "tablespoon", Plural: "tablespoons", Abbrev: "tbsp", System: Imperial, dimension: volume, base: 14.7868, min: 1}
+	fluidOunce = &Unit{Name: "fluid ounce", Plural: "fluid ounces", Abbrev: "fl oz", System: Imperial, dimension: volume, base: 29.5735}
+	cup        = &Unit{Name: "cup", Plural: "cups", System: Imperial, dimension: volume, base: 236.588, min: 1.0 / 4}
+	pint       = &Unit{Name: "pint", Plural: "pints", Abbrev: "pt", System: Imperial, dimension: volume, base: 473.176}
+	quart      = &Unit{Name: "quart", Plural: "quarts", Abbrev: "qt", System: Imperial, dimension: volume, base: 946.353}
+	gallon     = &Unit{Name: "gallon", Plural: "gallons", Abbrev: "gal", System: Imperial, dimension: volume, base: 3785.41, min: 4}
+	milliliter = &Unit{Name: "milliliter", Plural: "milliliters", Abbrev: "ml", System: Metric, dimension: volume, base: 1, min: 1}
+	deciliter  = &Unit{Name: "deciliter", Plural: "deciliters", Abbrev: "dl", System: Metric, dimension: volume, base: 100}
+	liter      = &Unit{Name: "liter", Plural: "liters", Abbrev: "l", System: Metric, dimension: volume, base: 1000, min: 1}
+	ounce      = &Unit{Name: "ounce", Plural: "ounces", Abbrev: "oz", System: Imperial, dimension: mass, base: 28.3495, min: 1.0 / 4}
+	pound      = &Unit{Name: "pound", Plural: "pounds", Abbrev: "lb", System: Imperial, dimension: mass, base: 453.592, min: 1}
+	milligram  = &Unit{Name: "milligram", Plural: "milligrams", Abbrev: "mg", System: Metric, dimension: mass, base: 0.001}
+	gram       = &Unit{Name: "gram", Plural: "grams", Abbrev: "g", System: Metric, dimension: mass, base: 1, min: 1}
+	kilogram   = &Unit{Name: "kilogram", Plural: "kilograms", Abbrev: "kg", System: Metric, dimension: mass, base: 1000, min: 1}
+	clove      = &Unit{Name: "clove", Plural: "cloves"}
+	can        = &Unit{Name: "can", Plural: "cans"}
+	pkg        = &Unit{Name: "package", Plural: "packages", Abbrev: "pkg"}
+	countUnits = []*Unit{
+		clove, can, pkg,
+		{Name: "pinch", Plural: "pinches"},
+		{Name: "dash", Plural: "dashes"},
+		{Name: "slice", Plural: "slices"},
+		{Name: "stick", Plural: "sticks"},
+		{Name: "bunch", Plural: "bunches"},
+		{Name: "sprig", Plural: "sprigs"},
+		{Name: "head", Plural: "heads"},
+		{Name: "piece", Plural: "pieces"},
+		{Name: "handful", Plural: "handfuls"},
+	}
+
+	measuredUnits = []*Unit{
+		teaspoon, tablespoon, fluidOunce, cup, pint, quart, gallon,
+		milliliter, deciliter, liter,
+		ounce, pound,
+		milligram, gram, kilogram,
+	}
+)
+
+// unitAlias is a way of writing a unit, and whether it's an abbreviation.
+type unitAlias struct {
+	unit   *Unit
+	abbrev bool
+}
+
+var (
+	// caseSensitiveAliases are checked before lowercasing, since "T" and "t"
+	// mean different things.
+	caseSensitiveAliases = map[string]unitAlias{
+		"T": {tablespoon, true},
+		"t": {teaspoon, true},
+	}
+	aliases = map[string]unitAlias{}
+)
+
+func init() {
+	for _, u := range append(append([]*Unit{}, measuredUnits...), countUnits...) {
+		aliases[u.Name] = unitAlias{u, false}
+		aliases[u.Plural] = unitAlias{u, false}
+		if u.Abbrev != "" {
+			aliases[u.Abbrev] = unitAlias{u, true}
+		}
+	}
+
+	extra := map[string]unitAlias{
+		"c":            {cup, false},
+		"tsps":         {teaspoon, true},
+		"tbs":          {tablespoon, true},
+		"tbl":          {tablespoon, true},
+		"tbsps":        {tablespoon, true},
+		"fl oz":        {fluidOunce, true},
+		"fluid oz":     {fluidOunce, true},
+		"mls":          {milliliter, true},
+		"millilitre":   {milliliter, false},
+		"millilitres":  {milliliter, false},
+		"litre":        {liter, false},
+		"litres":       {liter, false},
+		"lbs":          {pound, true},
+		"gr":           {gram, true},
+		"gs":           {gram, true},
+		"kgs":          {kilogram, true},
+		"pkgs":         {pkg, true},
+		"pts":          {pint, true},
+		"qts":          {quart, true},
+		"gals":         {gallon, true},
+		"decilitre":    {deciliter, false},
+		"decilitres":   {deciliter, false},
+		"fluid ounces": {fluidOunce, false},
+	}
+	for k, v := range extra {
+		aliases[k] = v
+	}
+}
+
+// lookupUnit finds the unit written as s, ignoring trailing periods, e.g.
+// "tbsp." or "oz.".
+func lookupUnit(s string) (unitAlias, bool) {
+	s = strings.ReplaceAll(strings.TrimRight(s, ".,"), ".", "")
+	if a, ok := caseSensitiveAliases[s]; ok {
+		return a, true
+	}
+	a, ok := aliases[strings.ToLower(s)]
+	return a, ok
+}
+
+// bestUnit picks the unit in the given system that reads best for the given
+// amount (in milliliters or grams), e.g. 3 teaspoons becomes 1 tablespoon.
+func bestUnit(amount float64, dim dimension, sys System) *Unit {
+	var best, smallest *Unit
+	for _, u := range measuredUnits {
+		if u.dimension != dim || u.System != sys || u.min == 0 {
+			continue
+		}
+		if smallest == nil || u.base < smallest.base {
+			smallest = u
+		}
+		if amount/u.base+1e-9 < u.min {
+			continue
+		}
+		if best == nil || u.base > best.base {
+			best = u
+		}
+	}
+	if best == nil {
+		return smallest
+	}
+	return best
+}
@@ -0,0 +1,480 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bcspragu/anylist/anylist"
+	"github.com/bcspragu/anylist/pb"
+	"github.com/bcspragu/anylist/store"
+)
+
+// ListSummary is a list without its items, as returned by /api/lists.
+type ListSummary struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	ItemCount      int    `json:"item_count"`
+	UncheckedCount int    `json:"unchecked_count"`
+	// Default is true for the list the server was configured to target.
+	Default bool `json:"default"`
+}
+
+// handleLists serves /api/lists, which lists every list in the account.
+func handleLists(st *store.Store, defaultListID func() string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, http.MethodGet) {
+			return
+		}
+
+		data, rev := st.Snapshot()
+		if notModified(w, r, rev) {
+			return
+		}
+
+		out := []ListSummary{}
+		for _, l := range data.GetShoppingListsResponse().GetNewLists() {
+			sum := ListSummary{
+				ID:        l.Identifier,
+				Name:      l.Name,
+				ItemCount: len(l.Items),
+				Default:   l.Identifier == defaultListID(),
+			}
+			for _, item := range l.Items {
+				if !item.Checked {
+					sum.UncheckedCount++
+				}
+			}
+			out = append(out, sum)
+		}
+		writeJSON(w, http.StatusOK, out)
+	}
+}
+
+// newItemRequest is the body of POST /api/lists/{id}/items.
+type newItemRequest struct {
+	Name     string `json:"name"`
+	Quantity string `json:"quantity"`
+	Details  string `json:"details"`
+}
+
+// updateItemRequest is the body of PATCH /api/lists/{id}/items/{itemID}.
+// Fields that are left out aren't changed.
+type updateItemRequest struct {
+	Name     *string `json:"name"`
+	Quantity *string `json:"quantity"`
+	Details  *string `json:"details"`
+	Checked  *bool   `json:"checked"`
+	// Version is the version of the item the changes are based on. It can
+	// also be given with an If-Match header.
+	Version string `json:"version"`
+}
+
+// handleListRoutes serves everything under /api/lists/:
+//
+//	GET    /api/lists/{id}
+//	GET    /api/lists/{id}/theme.css
+//	POST   /api/lists/{id}/items
+//	PATCH  /api/lists/{id}/items/{itemID}
+//	DELETE /api/lists/{id}/items/{itemID}
+//	POST   /api/lists/{id}/clear-checked
+//	POST   /api/lists/{id}/check-all
+//	POST   /api/lists/{id}/uncheck-all
+func handleListRoutes(c *anylist.Client, st *store.Store, undo *undoHistory) http.HandlerFunc {
+	themeCSS := handleListThemeCSS(st.Data)
+	return func(w http.ResponseWriter, r *http.Request) {
+		listID, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/lists/"), "/")
+		switch {
+		case rest == "":
+			handleGetList(w, r, st, listID)
+		case rest == "theme.css":
+			themeCSS(w, r)
+		case rest == "items":
+			handleAddListItem(w, r, c, st, undo, listID)
+		case strings.HasPrefix(rest, "items/"):
+			handleListItem(w, r, c, st, undo, listID, strings.TrimPrefix(rest, "items/"))
+		case rest == "clear-checked":
+			handleBulkChange(w, r, c, st, undo, listID, c.ClearCheckedOperations)
+		case rest == "check-all":
+			handleBulkChange(w, r, c, st, undo, listID, func(list *pb.ShoppingList) []*pb.PBListOperation {
+				return c.SetAllCheckedOperations(list, true)
+			})
+		case rest == "uncheck-all":
+			handleBulkChange(w, r, c, st, undo, listID, func(list *pb.ShoppingList) []*pb.PBListOperation {
+				return c.SetAllCheckedOperations(list, false)
+			})
+		default:
+			writeError(w, http.StatusNotFound, "not found")
+		}
+	}
+}
+
+func handleGetList(w http.ResponseWriter, r *http.Request, st *store.Store, listID string) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	data, rev := st.Snapshot()
+	list, ok := listByID(data.GetShoppingListsResponse().GetNewLists(), listID)
+	if !ok {
+		writeError(w, http.StatusNotFound, "list not found")
+		return
+	}
+	if notModified(w, r, rev) {
+		return
+	}
+	writeJSON(w, http.StatusOK, toList(data, list))
+}
+
+func handleAddListItem(w http.ResponseWriter, r *http.Request, c *anylist.Client, st *store.Store, undo *undoHistory, listID string) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+	if _, ok := listByID(st.Data().GetShoppingListsResponse().GetNewLists(), listID); !ok {
+		writeError(w, http.StatusNotFound, "list not found")
+		return
+	}
+
+	var req newItemRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "item name is required")
+		return
+	}
+
+	op, err := c.AddItemOperation(listID, &pb.ListItem{
+		Name:     req.Name,
+		Quantity: req.Quantity,
+		Details:  req.Details,
+	})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !sendChange(w, r, c, st, undo, listID, op) {
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, currentItem(st.Data(), listID, op.ListItem))
+}
+
+func handleListItem(w http.ResponseWriter, r *http.Request, c *anylist.Client, st *store.Store, undo *undoHistory, listID, itemID string) {
+	if !allowMethods(w, r, http.MethodPatch, http.MethodDelete) {
+		return
+	}
+
+	var req updateItemRequest
+	if r.Method == http.MethodDelete {
+		req.Version = r.URL.Query().Get("version")
+	} else {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		if req.Name != nil {
+			name := strings.TrimSpace(*req.Name)
+			if name == "" {
+				writeError(w, http.StatusBadRequest, "item name can't be empty")
+				return
+			}
+			req.Name = &name
+		}
+		if req.Name == nil && req.Quantity == nil && req.Details == nil && req.Checked == nil {
+			writeError(w, http.StatusBadRequest, "no changes")
+			return
+		}
+	}
+
+	unlock := st.LockList(listID)
+	defer unlock()
+	item, ok := itemForChange(w, st, listID, itemID, expectedVersion(r, req.Version))
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		if !sendChange(w, r, c, st, undo, listID, c.RemoveItemOperation(listID, item)) {
+			return
+		}
+		data := st.Data()
+		updated, ok := listByID(data.GetShoppingListsResponse().GetNewLists(), listID)
+		if !ok {
+			writeError(w, http.StatusNotFound, "list not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, toList(data, updated))
+		return
+	}
+
+	ops, err := c.UpdateItemOperations(listID, itemID, anylist.ItemUpdate{
+		Name:     req.Name,
+		Quantity: req.Quantity,
+		Details:  req.Details,
+		Checked:  req.Checked,
+		Original: item,
+	})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !sendChange(w, r, c, st, undo, listID, ops...) {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, currentItem(st.Data(), listID, item))
+}
+
+// handleBulkChange makes a change to many items on a list at once, like
+// clearing checked items, as a single batch. It returns the updated list.
+func handleBulkChange(w http.ResponseWriter, r *http.Request, c *anylist.Client, st *store.Store, undo *undoHistory, listID string, change func(list *pb.ShoppingList) []*pb.PBListOperation) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+	unlock := st.LockList(listID)
+	defer unlock()
+	list, ok := listByID(st.Data().GetShoppingListsResponse().GetNewLists(), listID)
+	if !ok {
+		writeError(w, http.StatusNotFound, "list not found")
+		return
+	}
+
+	if ops := change(list); len(ops) > 0 {
+		if !sendChange(w, r, c, st, undo, listID, ops...) {
+			return
+		}
+	}
+
+	data := st.Data()
+	updated, ok := listByID(data.GetShoppingListsResponse().GetNewLists(), listID)
+	if !ok {
+		writeError(w, http.StatusNotFound, "list not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, toList(data, updated))
+}
+
+// currentItem returns the latest version of an item from data, falling back to
+// the given copy of it if it can't be found, e.g. because a refresh replaced
+// the list in the meantime.
+func currentItem(data *pb.PBUserDataResponse, listID string, fallback *pb.ListItem) Item {
+	list, ok := listByID(data.GetShoppingListsResponse().GetNewLists(), listID)
+	if !ok {
+		return toItem(fallback, nil)
+	}
+	item, ok := itemByID(list, fallback.Identifier)
+	if !ok {
+		item = fallback
+	}
+	return toItem(item, userNames(list))
+}
+
+// addedItems returns the items added to a list by ops.
+func addedItems(list *pb.ShoppingList, ops []*pb.PBListOperation) []Item {
+	users := userNames(list)
+	items := []Item{}
+	for _, item := range anylist.AddedItems(ops) {
+		items = append(items, toItem(item, users))
+	}
+	return items
+}
+
+// sendChange sends a change to a list to AnyList as a single batch of
+// operations, applies it to the store, and records it so the requester can
+// undo it. If sending fails, it writes an error response and returns false.
+// The list should be locked if the change depends on what's on it.
+func sendChange(w http.ResponseWriter, r *http.Request, c *anylist.Client, st *store.Store, undo *undoHistory, listID string, ops ...*pb.PBListOperation) bool {
+	if len(ops) == 0 {
+		return true
+	}
+	err := c.SendListOperations(r.Context(), ops...)
+	if errors.Is(err, anylist.ErrNotProcessed) {
+		log.Printf("AnyList rejected changes to list %q: %v", listID, err)
+		// Pick up whatever changed, so the client can see it and try again.
+		if err := st.Refresh(r.Context()); err != nil {
+			log.Printf("failed to refresh lists: %v", err)
+		}
+		writeError(w, http.StatusConflict, "list was changed by someone else, try again")
+		return false
+	} else if err != nil {
+		log.Printf("failed to update list %q: %v", listID, err)
+		writeError(w, http.StatusBadGateway, "failed to update list")
+		return false
+	}
+	applyOperationsLocally(st, listID, ops)
+	undo.record(w, r, ops...)
+	return true
+}
+
+func itemByID(list *pb.ShoppingList, id string) (*pb.ListItem, bool) {
+	for _, item := range list.Items {
+		if item.Identifier == id {
+			return item, true
+		}
+	}
+	return nil, false
+}
+
+// handleAddItem serves /api/add, which adds the item named by the item_name
+// form value to the default list, and returns the added item.
+func handleAddItem(c *anylist.Client, st *store.Store, undo *undoHistory, defaultListID func() string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, http.MethodPost) {
+			return
+		}
+		name := strings.TrimSpace(r.PostFormValue("item_name"))
+		if name == "" {
+			writeError(w, http.StatusBadRequest, "item_name is required")
+			return
+		}
+
+		listID := defaultListID()
+		op, err := c.AddItemOperation(listID, &pb.ListItem{Name: name})
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if !sendChange(w, r, c, st, undo, listID, op) {
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, currentItem(st.Data(), listID, op.ListItem))
+	}
+}
+
+// handleRemoveItem serves /api/remove, which removes the item with the given
+// item_id from the default list, and returns the updated list.
+func handleRemoveItem(c *anylist.Client, st *store.Store, undo *undoHistory, defaultListID func() string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, http.MethodPost) {
+			return
+		}
+		itemID := r.PostFormValue("item_id")
+		if itemID == "" {
+			writeError(w, http.StatusBadRequest, "item_id is required")
+			return
+		}
+		listID := defaultListID()
+		unlock := st.LockList(listID)
+		defer unlock()
+		item, ok := itemForChange(w, st, listID, itemID, expectedVersion(r, r.PostFormValue("version")))
+		if !ok {
+			return
+		}
+
+		if !sendChange(w, r, c, st, undo, listID, c.RemoveItemOperation(listID, item)) {
+			return
+		}
+
+		data := st.Data()
+		updated, ok := listByID(data.GetShoppingListsResponse().GetNewLists(), listID)
+		if !ok {
+			writeError(w, http.StatusNotFound, "list not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, toList(data, updated))
+	}
+}
+
+// handleCheckItem serves /api/check, which checks or unchecks (based on the
+// checked form value) the item with the given item_id on the default list, and
+// returns the updated item.
+func handleCheckItem(c *anylist.Client, st *store.Store, undo *undoHistory, defaultListID func() string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, http.MethodPost) {
+			return
+		}
+		checked, err := strconv.ParseBool(r.PostFormValue("checked"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "checked must be true or false")
+			return
+		}
+		itemID := r.PostFormValue("item_id")
+		if itemID == "" {
+			writeError(w, http.StatusBadRequest, "item_id is required")
+			return
+		}
+		listID := defaultListID()
+		unlock := st.LockList(listID)
+		defer unlock()
+		item, ok := itemForChange(w, st, listID, itemID, expectedVersion(r, r.PostFormValue("version")))
+		if !ok {
+			return
+		}
+
+		ops, err := c.UpdateItemOperations(listID, item.Identifier, anylist.ItemUpdate{Checked: &checked, Original: item})
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if !sendChange(w, r, c, st, undo, listID, ops...) {
+			return
+		}
+
+		writeJSON(w, http.StatusOK, currentItem(st.Data(), listID, item))
+	}
+}
+
+// itemForChange looks up an item that's about to be changed, writing an error
+// response and returning false if it can't be found, or the request expected a
+// different version of it. The list must be locked, so nobody else can change
+// the item through this server between the check and the change.
+//
+// The check is against our copy of the item. Changes made elsewhere that we
+// haven't seen yet are caught by AnyList instead, since the item's original
+// values are sent along with the change, see sendChange.
+func itemForChange(w http.ResponseWriter, st *store.Store, listID, itemID, expected string) (*pb.ListItem, bool) {
+	list, ok := listByID(st.Data().GetShoppingListsResponse().GetNewLists(), listID)
+	if !ok {
+		writeError(w, http.StatusNotFound, "list not found")
+		return nil, false
+	}
+	item, ok := itemByID(list, itemID)
+	if !ok {
+		writeError(w, http.StatusNotFound, "item not found")
+		return nil, false
+	}
+	if !checkVersion(w, list, item, expected) {
+		return nil, false
+	}
+	return item, true
+}
+
+// conflictError is the body of a 409 Conflict response. It includes the item
+// as it is now, so the client can show it to the user or try again.
+type conflictError struct {
+	Error string `json:"error"`
+	Item  Item   `json:"item"`
+}
+
+// expectedVersion returns the version of an item a request expects to be
+// changing, from its If-Match header or, failing that, the given version
+// from the request body. It's empty if the request didn't give one, or gave
+// "If-Match: *", which any version matches.
+func expectedVersion(r *http.Request, version string) string {
+	if m := strings.TrimSpace(r.Header.Get("If-Match")); m != "" {
+		if m == "*" {
+			return ""
+		}
+		return strings.Trim(strings.TrimPrefix(m, "W/"), `"`)
+	}
+	return version
+}
+
+// checkVersion responds with 409 Conflict and returns false if the request
+// expected a different version of the item than the one we have.
+func checkVersion(w http.ResponseWriter, list *pb.ShoppingList, item *pb.ListItem, expected string) bool {
+	if expected == "" || expected == itemVersion(item) {
+		return true
+	}
+	writeJSON(w, http.StatusConflict, conflictError{
+		Error: "item was changed by someone else",
+		Item:  toItem(item, userNames(list)),
+	})
+	return false
+}
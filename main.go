@@ -5,15 +5,17 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
 	"strconv"
-	"time"
+	"strings"
 
 	"github.com/bcspragu/anylist/anylist"
 	"github.com/bcspragu/anylist/pb"
+	"github.com/bcspragu/anylist/store"
 	"github.com/namsral/flag"
 	"github.com/rs/cors"
 	"go.mozilla.org/sops/v3/decrypt"
@@ -23,6 +25,9 @@ type SecretConfig struct {
 	Email        string `json:"email"`
 	Password     string `json:"password"`
 	RefreshToken string `json:"refresh_token"`
+	// ICSToken protects the meal plan calendar feed. The feed is disabled if
+	// it's empty.
+	ICSToken string `json:"ics_token"`
 }
 
 func main() {
@@ -40,7 +45,8 @@ func run(args []string) error {
 	var (
 		sopsConfigPath  = fs.String("sops_encrypted_config", "secrets.enc.json", "A JSON-formatted configuration file for our main server, parseable by the SOPS tool (https://github.com/mozilla/sops).")
 		port            = fs.Int("port", 8080, "The port to serve the  HTTP API service on.")
-		groceryListName = fs.String("grocery_list_name", "Grokeries 2.0", "The name of the AnyList list to target.")
+		groceryListName = fs.String("grocery_list_name", "", "The name of the AnyList list to target. Defaults to the default list from the AnyList app settings.")
+		publicBaseURL   = fs.String("public_base_url", "", "The externally visible base URL of the site (e.g. https://list.example.com), used for links in the meal plan calendar feed.")
 	)
 	// Allows for passing in configuration via a -config path/to/env-file.conf
 	// flag, see https://pkg.go.dev/github.com/namsral/flag#readme-usage
@@ -63,81 +69,91 @@ func run(args []string) error {
 		return fmt.Errorf("failed to init anylist client: %w", err)
 	}
 
-	// fmt.Println("connecting to WS")
-	// if err := c.Listen(msgHandler); err != nil {
-	// 	return fmt.Errorf("failed to listen for messages: %w", err)
-	// }
-
-	var list *List
-	refreshList := func(ctx context.Context) error {
+	// Only accept data that has the list we're targeting, so a bad refresh
+	// doesn't leave us without one.
+	load := func(ctx context.Context) (*pb.PBUserDataResponse, error) {
 		resp, err := c.Lists(ctx)
 		if err != nil {
-			return fmt.Errorf("failed to load lists: %w", err)
+			return nil, fmt.Errorf("failed to load lists: %w", err)
 		}
-		tmp, err := toList(resp, *groceryListName)
-		if err != nil {
-			return fmt.Errorf("failed to convert list: %w", err)
+		if _, err := targetList(resp, *groceryListName); err != nil {
+			return nil, fmt.Errorf("failed to find list: %w", err)
 		}
-		list = tmp
-		return nil
+		return resp, nil
 	}
-
-	if err := refreshList(ctx); err != nil {
+	st, err := store.New(ctx, load)
+	if err != nil {
 		return fmt.Errorf("failed initial list load: %w", err)
 	}
+	state := &serverState{store: st, listName: *groceryListName}
+	undo := newUndoHistory()
 
-	// This is just a hack to keep the server up to date with other people's
-	// changes. We should remove this if we ever get websockets working.
-	go func() {
-		t := time.NewTicker(10 * time.Minute)
-		for {
-			select {
-			case <-t.C:
-				refreshList(ctx)
-			case <-ctx.Done():
-				return
-			}
-		}
-	}()
+	// Keep up with changes made on other devices, so they're pushed out to
+	// anyone on /api/events.
+	go pollForChanges(ctx, st)
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/api/list", func(w http.ResponseWriter, r *http.Request) {
-		json.NewEncoder(w).Encode(list)
-	})
-	mux.HandleFunc("/api/add", func(w http.ResponseWriter, r *http.Request) {
-		itemName := r.PostFormValue("item_name")
-		if err := c.AddItem(r.Context(), list.ID, itemName); err != nil {
-			log.Printf("failed to add item %q: %v", itemName, err)
+		if !allowMethods(w, r, http.MethodGet) {
 			return
 		}
-		if err := refreshList(r.Context()); err != nil {
-			log.Printf("failed to refresh list: %v", err)
-			return
-		}
-	})
-	mux.HandleFunc("/api/remove", func(w http.ResponseWriter, r *http.Request) {
-		itemID := r.PostFormValue("item_id")
-		if err := c.RemoveItem(r.Context(), list.ID, itemID); err != nil {
-			log.Printf("failed to remove item %q: %v", itemID, err)
+		snap := state.snapshot()
+		if notModified(w, r, snap.revision) {
 			return
 		}
-		if err := refreshList(r.Context()); err != nil {
-			log.Printf("failed to refresh list: %v", err)
+		list, ok := listByID(snap.data.GetShoppingListsResponse().GetNewLists(), snap.defaultListID)
+		if !ok {
+			writeError(w, http.StatusNotFound, "list not found")
 			return
 		}
+		writeJSON(w, http.StatusOK, toList(snap.data, list))
 	})
-	mux.HandleFunc("/api/check", func(w http.ResponseWriter, r *http.Request) {
-		itemID := r.PostFormValue("item_id")
-		checked := r.PostFormValue("checked") == "true"
-		if err := c.SetChecked(r.Context(), list.ID, itemID, checked); err != nil {
-			log.Printf("failed to update checked (%q, %t): %v", itemID, checked, err)
+	mux.HandleFunc("/api/suggest", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, http.MethodGet) {
 			return
 		}
-		if err := refreshList(r.Context()); err != nil {
-			log.Printf("failed to refresh list: %v", err)
-			return
+		q := r.URL.Query().Get("q")
+		limit := 10
+		if l := r.URL.Query().Get("limit"); l != "" {
+			n, err := strconv.Atoi(l)
+			if err != nil || n <= 0 {
+				writeError(w, http.StatusBadRequest, "invalid limit")
+				return
+			}
+			limit = n
+		}
+		writeJSON(w, http.StatusOK, state.snapshot().suggester.Suggest(q, limit))
+	})
+	getRecipeData := func() *anylist.RecipeData { return state.snapshot().recipeData }
+	recipes := handleRecipes(getRecipeData)
+	mux.HandleFunc("/api/recipes", recipes)
+	getListID := func() string { return state.snapshot().defaultListID }
+	addRecipeToList := handleAddRecipeToList(c, st, undo, getListID)
+	printRecipe := handlePrintRecipe(getRecipeData)
+	mux.HandleFunc("/api/recipes/", func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/add-to-list"):
+			addRecipeToList(w, r)
+		case strings.HasSuffix(r.URL.Path, "/print"):
+			printRecipe(w, r)
+		default:
+			recipes(w, r)
 		}
 	})
+	mux.HandleFunc("/api/recipe-collections", handleRecipeCollections(getRecipeData))
+	mux.HandleFunc("/api/recipe-collections/", handleRecipeCollections(getRecipeData))
+	getMealPlan := func() *anylist.MealPlan { return state.snapshot().mealPlan }
+	mux.HandleFunc("/api/mealplan", handleMealPlan(getMealPlan))
+	mux.HandleFunc("/api/mealplan/shopping-list", handleMealPlanShoppingList(c, st, undo, getListID))
+	mux.HandleFunc("/api/mealplan.ics", handleMealPlanICS(getMealPlan, secCfg.ICSToken, *publicBaseURL))
+	mux.HandleFunc("/api/account", handleAccount(c))
+	mux.HandleFunc("/api/lists", handleLists(st, getListID))
+	mux.HandleFunc("/api/lists/", handleListRoutes(c, st, undo))
+	mux.HandleFunc("/api/events", handleEvents(st))
+	mux.HandleFunc("/api/add", handleAddItem(c, st, undo, getListID))
+	mux.HandleFunc("/api/remove", handleRemoveItem(c, st, undo, getListID))
+	mux.HandleFunc("/api/check", handleCheckItem(c, st, undo, getListID))
+	mux.HandleFunc("/api/undo", handleUndo(c, st, undo))
 	if err := http.ListenAndServe(":"+strconv.Itoa(*port), cors.Default().Handler(mux)); err != nil {
 		return fmt.Errorf("failed to run HTTP server: %w", err)
 	}
@@ -149,6 +165,9 @@ type List struct {
 	ID    string `json:"id"`
 	Name  string `json:"name"`
 	Items []Item `json:"items"`
+	// Theme is the list's effective theme, so the web UI can match the phone
+	// app.
+	Theme *Theme `json:"theme"`
 }
 
 type Item struct {
@@ -156,43 +175,124 @@ type Item struct {
 	Name    string `json:"name"`
 	Details string `json:"details"`
 	Checked bool   `json:"checked"`
+	// Quantity is how much of the item is needed, e.g. "2 lbs".
+	Quantity string `json:"quantity,omitempty"`
+	// AddedBy is the display name of the user who added the item, if we know
+	// who that was.
+	AddedBy string `json:"added_by,omitempty"`
+	// Category is the name of the category the item is filed under, unless the
+	// list is set to hide categories.
+	Category string `json:"category,omitempty"`
+	// Version identifies this state of the item. Clients can send it back
+	// with changes to make sure nobody else changed the item first.
+	Version string `json:"version"`
 }
 
-func toList(in *pb.PBUserDataResponse, targetListName string) (*List, error) {
-	lists := in.ShoppingListsResponse.NewLists
-	list, ok := listByName(lists, targetListName)
+// targetList returns the list the server should target by default, the one
+// with the given name, or the default list from the app settings if name is
+// empty.
+func targetList(in *pb.PBUserDataResponse, name string) (*pb.ShoppingList, error) {
+	if name != "" {
+		list, ok := listByName(in.ShoppingListsResponse.NewLists, name)
+		if !ok {
+			return nil, fmt.Errorf("no list with name %q found", name)
+		}
+		return list, nil
+	}
+	list, ok := anylist.DefaultList(in)
 	if !ok {
-		return nil, fmt.Errorf("no list with name %q found", targetListName)
+		return nil, errors.New("no list name given and no default list set in the app")
+	}
+	return list, nil
+}
+
+func toList(in *pb.PBUserDataResponse, list *pb.ShoppingList) *List {
+	// Honor the display settings from the phone app, if there are any.
+	settings, _ := anylist.ListSettings(in, list.Identifier)
+	var categories map[string]string
+	if !settings.GetShouldHideCategories() {
+		categories = anylist.ListCategoryNames(in, list.Identifier)
 	}
 
-	var items []Item
+	users := userNames(list)
+	items := []Item{}
 	for _, item := range list.Items {
-		items = append(items, Item{
-			ID:      item.Identifier,
-			Name:    item.Name,
-			Details: item.Details,
-			Checked: item.Checked,
-		})
+		if item.Checked && settings.GetShouldHideCompletedItems() {
+			continue
+		}
+		it := toItem(item, users)
+		it.Category = anylist.ItemCategory(item, categories)
+		items = append(items, it)
 	}
 
 	return &List{
 		ID:    list.Identifier,
 		Name:  list.Name,
 		Items: items,
-	}, nil
+		Theme: listTheme(in, list.Identifier),
+	}
 }
 
-func listByName(lists []*pb.ShoppingList, target string) (*pb.ShoppingList, bool) {
+// toItem converts a list item, resolving who added it with users, as returned
+// by userNames.
+func toItem(item *pb.ListItem, users map[string]string) Item {
+	return Item{
+		ID:       item.Identifier,
+		Name:     item.Name,
+		Details:  item.Details,
+		Checked:  item.Checked,
+		Quantity: item.Quantity,
+		AddedBy:  users[item.UserId],
+		Version:  itemVersion(item),
+	}
+}
+
+// itemVersion returns a version for an item that changes whenever any of the
+// fields people can see and change do. It only depends on those fields, so an
+// item we changed locally has the same version once it's refreshed from
+// AnyList.
+//
+// That means an item that was changed and then changed back has the same
+// version it started with, so a change based on the original passes the
+// check. That's fine: the change was made against exactly what's there now,
+// and is sent with those values as the originals, so AnyList sees it the same
+// way.
+func itemVersion(item *pb.ListItem) string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%t", item.Name, item.Quantity, item.Details, item.Checked)
+	return strconv.FormatUint(h.Sum64(), 36)
+}
+
+// userNames maps the IDs of the users a list is shared with to their display
+// names, which is their full name if they've set one, or their email address.
+func userNames(list *pb.ShoppingList) map[string]string {
+	out := make(map[string]string)
+	for _, u := range list.SharedUsers {
+		name := u.FullName
+		if name == "" {
+			name = u.Email
+		}
+		out[u.UserId] = name
+	}
+	return out
+}
+
+func listByID(lists []*pb.ShoppingList, id string) (*pb.ShoppingList, bool) {
 	for _, l := range lists {
-		if l.Name == target {
+		if l.Identifier == id {
 			return l, true
 		}
 	}
 	return nil, false
 }
 
-func msgHandler(msg string) {
-	fmt.Println("Received message!", msg)
+func listByName(lists []*pb.ShoppingList, target string) (*pb.ShoppingList, bool) {
+	for _, l := range lists {
+		if l.Name == target {
+			return l, true
+		}
+	}
+	return nil, false
 }
 
 func decryptConfig(secPath string) (*SecretConfig, error) {
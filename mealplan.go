@@ -0,0 +1,260 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bcspragu/anylist/anylist"
+	"github.com/bcspragu/anylist/ics"
+	"github.com/bcspragu/anylist/pb"
+	"github.com/bcspragu/anylist/store"
+)
+
+type MealPlan struct {
+	From string        `json:"from"`
+	To   string        `json:"to"`
+	Days []MealPlanDay `json:"days"`
+}
+
+type MealPlanDay struct {
+	Date   string          `json:"date"`
+	Events []MealPlanEvent `json:"events"`
+}
+
+type MealPlanEvent struct {
+	ID      string         `json:"id"`
+	Date    string         `json:"date"`
+	Title   string         `json:"title"`
+	Details string         `json:"details,omitempty"`
+	Label   *MealPlanLabel `json:"label,omitempty"`
+	Recipe  *RecipeSummary `json:"recipe,omitempty"`
+	// RecipeScaleFactor is only set for events linked to a recipe.
+	RecipeScaleFactor float64 `json:"recipe_scale_factor,omitempty"`
+}
+
+type MealPlanLabel struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+// handleMealPlan serves /api/mealplan?from=&to=, which returns the meal plan
+// for each day between from and to (inclusive, formatted like 2006-01-02).
+// from defaults to the start of the current week, and to defaults to a week
+// after from.
+func handleMealPlan(mealPlan func() *anylist.MealPlan) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, http.MethodGet) {
+			return
+		}
+		from, to, err := parseDateRange(r, time.Now())
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		events := mealPlan().Events(from, to)
+		byDate := make(map[string][]MealPlanEvent)
+		for _, e := range events {
+			byDate[e.Event.Date] = append(byDate[e.Event.Date], toMealPlanEvent(e))
+		}
+
+		out := MealPlan{
+			From: from.Format(anylist.DateLayout),
+			To:   to.Format(anylist.DateLayout),
+			Days: []MealPlanDay{},
+		}
+		for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+			date := d.Format(anylist.DateLayout)
+			evts := byDate[date]
+			if evts == nil {
+				evts = []MealPlanEvent{}
+			}
+			out.Days = append(out.Days, MealPlanDay{Date: date, Events: evts})
+		}
+		writeJSON(w, http.StatusOK, out)
+	}
+}
+
+// handleMealPlanShoppingList serves POST /api/mealplan/shopping-list, which
+// adds the ingredients for every recipe planned between from and to
+// (defaulting to the current week) to the list given by list_id, falling back
+// to defaultListID. Ingredients shared by multiple recipes are combined. The
+// added items are returned.
+func handleMealPlanShoppingList(c *anylist.Client, st *store.Store, undo *undoHistory, defaultListID func() string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, http.MethodPost) {
+			return
+		}
+
+		from, to, err := parseDateRange(r, time.Now())
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		listID := r.PostFormValue("list_id")
+		if listID == "" {
+			listID = defaultListID()
+		}
+
+		// What gets added depends on what's already on the list.
+		unlock := st.LockList(listID)
+		defer unlock()
+		resp := st.Data()
+		list, ok := listByID(resp.GetShoppingListsResponse().GetNewLists(), listID)
+		if !ok {
+			writeError(w, http.StatusNotFound, "list not found")
+			return
+		}
+
+		events := anylist.NewMealPlan(resp).Events(from, to)
+		ops, err := c.AddMealPlanToListOperations(list, events)
+		if err != nil {
+			log.Printf("failed to add meal plan to list %q: %v", listID, err)
+			writeError(w, http.StatusInternalServerError, "failed to add meal plan to list")
+			return
+		}
+		if !sendChange(w, r, c, st, undo, listID, ops...) {
+			return
+		}
+
+		writeJSON(w, http.StatusOK, addedItems(list, ops))
+	}
+}
+
+// maxDateRange keeps requests from asking us to render years of empty days.
+const maxDateRange = 366 * 24 * time.Hour
+
+// parseDateRange reads the from and to parameters, either from the query
+// string or a posted form, defaulting to the week (starting on Sunday)
+// containing now.
+func parseDateRange(r *http.Request, now time.Time) (time.Time, time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	from := today.AddDate(0, 0, -int(today.Weekday()))
+	if s := r.FormValue("from"); s != "" {
+		t, err := time.Parse(anylist.DateLayout, s)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("invalid from date, expected YYYY-MM-DD")
+		}
+		from = t
+	}
+
+	to := from.AddDate(0, 0, 6)
+	if s := r.FormValue("to"); s != "" {
+		t, err := time.Parse(anylist.DateLayout, s)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("invalid to date, expected YYYY-MM-DD")
+		}
+		to = t
+	}
+
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, errors.New("to date is before from date")
+	}
+	if to.Sub(from) > maxDateRange {
+		return time.Time{}, time.Time{}, errors.New("date range is too large")
+	}
+	return from, to, nil
+}
+
+func toMealPlanEvent(e *anylist.MealPlanEvent) MealPlanEvent {
+	out := MealPlanEvent{
+		ID:      e.Event.Identifier,
+		Date:    e.Event.Date,
+		Title:   e.Title(),
+		Details: e.Event.Details,
+	}
+	if e.Label != nil {
+		out.Label = toMealPlanLabel(e.Label)
+	}
+	if e.Recipe != nil {
+		rs := toRecipeSummary(e.Recipe)
+		out.Recipe = &rs
+		out.RecipeScaleFactor = e.Event.RecipeScaleFactor
+		if out.RecipeScaleFactor == 0 {
+			out.RecipeScaleFactor = 1
+		}
+	}
+	return out
+}
+
+func toMealPlanLabel(l *pb.PBCalendarLabel) *MealPlanLabel {
+	return &MealPlanLabel{
+		ID:    l.Identifier,
+		Name:  l.Name,
+		Color: l.HexColor,
+	}
+}
+
+// handleMealPlanICS serves /api/mealplan.ics?token=, an iCalendar feed of the
+// meal plan that calendar apps can subscribe to. Calendar apps can't send
+// credentials, so the feed is protected by a token in the URL instead. If
+// baseURL is set, events link to the printable version of their recipe,
+// otherwise to the recipe's source.
+func handleMealPlanICS(mealPlan func() *anylist.MealPlan, token, baseURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, http.MethodGet, http.MethodHead) {
+			return
+		}
+		if token == "" {
+			writeError(w, http.StatusNotFound, "meal plan feed is not enabled")
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("token")), []byte(token)) != 1 {
+			writeError(w, http.StatusForbidden, "invalid token")
+			return
+		}
+
+		now := time.Now()
+		events := mealPlan().Events(now.AddDate(0, 0, -icsPastDays), now.AddDate(0, 0, icsFutureDays))
+
+		cal := &ics.Calendar{Name: "Meal Plan"}
+		for _, e := range events {
+			cal.Events = append(cal.Events, toICSEvent(e, baseURL))
+		}
+
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		if err := ics.Encode(w, cal, now); err != nil {
+			log.Printf("failed to write meal plan feed: %v", err)
+		}
+	}
+}
+
+// The window of events included in the feed, relative to today.
+const (
+	icsPastDays   = 90
+	icsFutureDays = 365
+)
+
+func toICSEvent(e *anylist.MealPlanEvent, baseURL string) ics.Event {
+	out := ics.Event{
+		UID:         e.Event.Identifier + "@anylist.com",
+		Date:        e.Date,
+		Summary:     e.Title(),
+		Description: e.Event.Details,
+	}
+	if e.Label != nil {
+		out.Categories = []string{e.Label.Name}
+	}
+	if e.Recipe != nil {
+		switch {
+		case baseURL != "":
+			out.URL = strings.TrimRight(baseURL, "/") + "/api/recipes/" + url.PathEscape(e.Recipe.Identifier) + "/print"
+		case e.Recipe.SourceUrl != "":
+			out.URL = e.Recipe.SourceUrl
+		}
+		if out.URL != "" {
+			if out.Description != "" {
+				out.Description += "\n\n"
+			}
+			out.Description += "Recipe: " + out.URL
+		}
+	}
+	return out
+}
@@ -0,0 +1,218 @@
+package paprika
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bcspragu/anylist/anylist"
+	"github.com/bcspragu/anylist/ingredient"
+	"github.com/bcspragu/anylist/pb"
+	"github.com/google/uuid"
+)
+
+const createdLayout = "2006-01-02 15:04:05"
+
+// ToPB converts a Paprika recipe to an AnyList recipe with a new identifier.
+// The Paprika UID is kept as the recipe's Paprika identifier. AnyList recipes
+// don't have a description, so it goes at the top of the note, above a "---"
+// line, where FromPB finds it again.
+func (r *Recipe) ToPB() *pb.PBRecipe {
+	var ingredients []*pb.PBIngredient
+	for _, line := range lines(r.Ingredients) {
+		ing := ingredient.Parse(line).ToPB()
+		ing.RawIngredient = line
+		ingredients = append(ingredients, ing)
+	}
+
+	now := anylist.Timestamp(time.Now())
+	created := now
+	if t, err := time.ParseInLocation(createdLayout, r.Created, time.Local); err == nil {
+		created = anylist.Timestamp(t)
+	}
+
+	var photoURLs []string
+	if r.ImageURL != "" {
+		photoURLs = []string{r.ImageURL}
+	}
+
+	prep := parseDuration(r.PrepTime)
+	cook := parseDuration(r.CookTime)
+	if total := parseDuration(r.TotalTime); cook == 0 && total > prep {
+		cook = total - prep
+	}
+
+	return &pb.PBRecipe{
+		Identifier:        uuid.NewString(),
+		Timestamp:         now,
+		Name:              r.Name,
+		Note:              joinNote(r.Description, r.Notes),
+		SourceName:        r.Source,
+		SourceUrl:         r.SourceURL,
+		Ingredients:       ingredients,
+		PreparationSteps:  lines(r.Directions),
+		PhotoUrls:         photoURLs,
+		ScaleFactor:       1,
+		Rating:            int32(r.Rating),
+		CreationTimestamp: created,
+		NutritionalInfo:   r.NutritionalInfo,
+		PrepTime:          int32(prep / time.Second),
+		CookTime:          int32(cook / time.Second),
+		Servings:          r.Servings,
+		PaprikaIdentifier: r.UID,
+	}
+}
+
+// FromPB converts an AnyList recipe to a Paprika recipe. Recipes that came
+// from Paprika keep their original UID, others use their AnyList identifier.
+func FromPB(in *pb.PBRecipe) *Recipe {
+	uid := in.PaprikaIdentifier
+	if uid == "" {
+		uid = strings.ToUpper(in.Identifier)
+	}
+
+	var ingredients []string
+	for _, ing := range in.Ingredients {
+		line := ing.RawIngredient
+		if line == "" {
+			line = ingredient.FromPB(ing).String()
+		}
+		ingredients = append(ingredients, line)
+	}
+
+	var imageURL string
+	if len(in.PhotoUrls) > 0 {
+		imageURL = in.PhotoUrls[0]
+	}
+
+	var created string
+	if in.CreationTimestamp > 0 {
+		created = anylist.TimestampTime(in.CreationTimestamp).Format(createdLayout)
+	}
+
+	var total int32
+	if in.PrepTime > 0 && in.CookTime > 0 {
+		total = in.PrepTime + in.CookTime
+	}
+
+	description, notes := splitNote(in.Note)
+
+	return &Recipe{
+		UID:             uid,
+		Name:            in.Name,
+		Ingredients:     strings.Join(ingredients, "\n"),
+		Directions:      strings.Join(in.PreparationSteps, "\n"),
+		Description:     description,
+		Notes:           notes,
+		NutritionalInfo: in.NutritionalInfo,
+		PrepTime:        anylist.FormatRecipeTime(in.PrepTime),
+		CookTime:        anylist.FormatRecipeTime(in.CookTime),
+		TotalTime:       anylist.FormatRecipeTime(total),
+		Servings:        in.Servings,
+		Rating:          int(in.Rating),
+		Source:          in.SourceName,
+		SourceURL:       in.SourceUrl,
+		ImageURL:        imageURL,
+		Categories:      []string{},
+		Created:         created,
+	}
+}
+
+// Import converts Paprika recipes to AnyList recipes. Recipes that already
+// exist in AnyList, either because they were imported from Paprika before or
+// were exported from AnyList in the first place, keep their existing
+// identifier and are returned in updated, so saving them doesn't create
+// duplicates. Everything else is returned in created.
+func Import(recipes []*Recipe, existing []*pb.PBRecipe) (created, updated []*pb.PBRecipe) {
+	byUID := make(map[string]*pb.PBRecipe)
+	for _, r := range existing {
+		byUID[strings.ToUpper(r.Identifier)] = r
+		if r.PaprikaIdentifier != "" {
+			byUID[strings.ToUpper(r.PaprikaIdentifier)] = r
+		}
+	}
+
+	for _, rec := range recipes {
+		out := rec.ToPB()
+		prev, ok := byUID[strings.ToUpper(rec.UID)]
+		if !ok {
+			created = append(created, out)
+			continue
+		}
+		out.Identifier = prev.Identifier
+		out.CreationTimestamp = prev.CreationTimestamp
+		out.PhotoIds = prev.PhotoIds
+		updated = append(updated, out)
+	}
+	return created, updated
+}
+
+// descriptionSeparator ends a Paprika description in an AnyList recipe's
+// note, since AnyList recipes only have the note.
+const descriptionSeparator = "\n\n---"
+
+// joinNote combines a Paprika description and notes into an AnyList note,
+// with the description first, in a way splitNote can undo.
+func joinNote(description, notes string) string {
+	description, notes = strings.TrimSpace(description), strings.TrimSpace(notes)
+	if description == "" {
+		return notes
+	}
+	note := description + descriptionSeparator
+	if notes != "" {
+		note += "\n\n" + notes
+	}
+	return note
+}
+
+// splitNote splits an AnyList note made by joinNote back into a description
+// and notes. Notes without a description are returned as they are.
+func splitNote(note string) (description, notes string) {
+	i := strings.Index(note, descriptionSeparator)
+	if i < 0 {
+		return "", note
+	}
+	rest := note[i+len(descriptionSeparator):]
+	if rest != "" && !strings.HasPrefix(rest, "\n\n") {
+		// Something like "----" that isn't our separator.
+		return "", note
+	}
+	return note[:i], strings.TrimPrefix(rest, "\n\n")
+}
+
+// lines splits a block of text into its non-empty, trimmed lines.
+func lines(s string) []string {
+	var out []string
+	for _, l := range strings.Split(s, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+var durationPartRE = regexp.MustCompile(`(?i)(\d+(?:\.\d+)?)\s*(hours?|hrs?|h|minutes?|mins?|m)\b`)
+
+// parseDuration parses Paprika's free-form times, like "1 hr 30 mins" or
+// "45 minutes". A bare number is taken to be minutes.
+func parseDuration(s string) time.Duration {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(n * float64(time.Minute))
+	}
+
+	var d time.Duration
+	for _, m := range durationPartRE.FindAllStringSubmatch(s, -1) {
+		n, err := strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			continue
+		}
+		unit := time.Minute
+		if strings.HasPrefix(strings.ToLower(m[2]), "h") {
+			unit = time.Hour
+		}
+		d += time.Duration(n * float64(unit))
+	}
+	return d
+}
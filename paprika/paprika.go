@@ -0,0 +1,173 @@
+// Package paprika reads and writes Paprika recipe archives
+// (.paprikarecipes files) and maps their recipes to and from AnyList's.
+//
+// An archive is a zip file with one entry per recipe, and each entry is a
+// gzipped JSON document.
+package paprika
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Recipe is a recipe as Paprika stores it.
+type Recipe struct {
+	UID             string   `json:"uid"`
+	Name            string   `json:"name"`
+	Ingredients     string   `json:"ingredients"`
+	Directions      string   `json:"directions"`
+	Description     string   `json:"description"`
+	Notes           string   `json:"notes"`
+	NutritionalInfo string   `json:"nutritional_info"`
+	PrepTime        string   `json:"prep_time"`
+	CookTime        string   `json:"cook_time"`
+	TotalTime       string   `json:"total_time"`
+	Difficulty      string   `json:"difficulty"`
+	Servings        string   `json:"servings"`
+	Rating          int      `json:"rating"`
+	Source          string   `json:"source"`
+	SourceURL       string   `json:"source_url"`
+	ImageURL        string   `json:"image_url"`
+	Photo           string   `json:"photo"`
+	PhotoHash       string   `json:"photo_hash"`
+	PhotoData       string   `json:"photo_data"`
+	Categories      []string `json:"categories"`
+	// Created is formatted like "2006-01-02 15:04:05".
+	Created string `json:"created"`
+	Hash    string `json:"hash"`
+}
+
+// OpenArchive reads every recipe from the archive at path.
+func OpenArchive(path string) ([]*Recipe, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat archive: %w", err)
+	}
+	return ReadArchive(f, fi.Size())
+}
+
+// ReadArchive reads every recipe from an archive.
+func ReadArchive(r io.ReaderAt, size int64) ([]*Recipe, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	var out []*Recipe
+	for _, zf := range zr.File {
+		if zf.FileInfo().IsDir() {
+			continue
+		}
+		rec, err := readEntry(zf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read recipe %q: %w", zf.Name, err)
+		}
+		out = append(out, rec)
+	}
+	return out, nil
+}
+
+func readEntry(zf *zip.File) (*Recipe, error) {
+	rc, err := zf.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive entry: %w", err)
+	}
+	defer rc.Close()
+
+	gr, err := gzip.NewReader(rc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init gzip reader: %w", err)
+	}
+	defer gr.Close()
+
+	var rec Recipe
+	if err := json.NewDecoder(gr).Decode(&rec); err != nil {
+		return nil, fmt.Errorf("failed to decode recipe JSON: %w", err)
+	}
+	return &rec, nil
+}
+
+// WriteArchive writes the recipes as an archive. Recipes without a hash are
+// written with one computed, the recipes themselves aren't modified.
+func WriteArchive(w io.Writer, recipes []*Recipe) error {
+	zw := zip.NewWriter(w)
+
+	used := make(map[string]bool)
+	for _, rec := range recipes {
+		if rec.Hash == "" {
+			h, err := hash(rec)
+			if err != nil {
+				return fmt.Errorf("failed to hash recipe %q: %w", rec.Name, err)
+			}
+			// Don't modify the caller's recipe.
+			withHash := *rec
+			withHash.Hash = h
+			rec = &withHash
+		}
+
+		// Paprika doesn't care about entry names, but zip tools don't like
+		// duplicates.
+		name := entryName(rec.Name)
+		base := strings.TrimSuffix(name, ".paprikarecipe")
+		for n := 2; used[name]; n++ {
+			name = base + " (" + strconv.Itoa(n) + ").paprikarecipe"
+		}
+		used[name] = true
+
+		fw, err := zw.Create(name)
+		if err != nil {
+			return fmt.Errorf("failed to create archive entry: %w", err)
+		}
+		gw := gzip.NewWriter(fw)
+		if err := json.NewEncoder(gw).Encode(rec); err != nil {
+			return fmt.Errorf("failed to encode recipe %q: %w", rec.Name, err)
+		}
+		if err := gw.Close(); err != nil {
+			return fmt.Errorf("failed to compress recipe %q: %w", rec.Name, err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %w", err)
+	}
+	return nil
+}
+
+var unsafeNameChars = regexp.MustCompile(`[/\\:*?"<>|]`)
+
+func entryName(recipeName string) string {
+	name := strings.TrimSpace(unsafeNameChars.ReplaceAllString(recipeName, "-"))
+	if name == "" {
+		name = "Untitled"
+	}
+	return name + ".paprikarecipe"
+}
+
+// hash computes a content hash for the recipe, which Paprika uses to detect
+// changes.
+func hash(rec *Recipe) (string, error) {
+	tmp := *rec
+	tmp.Hash = ""
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(tmp); err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	return strings.ToUpper(hex.EncodeToString(sum[:])), nil
+}
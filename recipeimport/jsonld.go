@@ -0,0 +1,194 @@
+package recipeimport
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	xhtml "golang.org/x/net/html"
+)
+
+// jsonLDScripts returns the contents of every JSON-LD script in the document.
+func jsonLDScripts(n *xhtml.Node) []string {
+	var out []string
+	var walk func(*xhtml.Node)
+	walk = func(n *xhtml.Node) {
+		if n.Type == xhtml.ElementNode && n.Data == "script" && strings.EqualFold(attr(n, "type"), "application/ld+json") {
+			var sb strings.Builder
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type == xhtml.TextNode {
+					sb.WriteString(c.Data)
+				}
+			}
+			out = append(out, sb.String())
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return out
+}
+
+// recipeFromJSONLD looks for a Recipe anywhere in a JSON-LD document, which
+// might be a single object, a list of objects, or an @graph.
+func recipeFromJSONLD(script string) (*schemaRecipe, bool) {
+	var v any
+	if err := json.Unmarshal([]byte(script), &v); err != nil {
+		return nil, false
+	}
+	obj, ok := findRecipe(v)
+	if !ok {
+		return nil, false
+	}
+
+	rec := &schemaRecipe{
+		Name:        jsonString(obj["name"]),
+		Description: jsonString(obj["description"]),
+		URL:         jsonString(obj["url"]),
+		Publisher:   jsonName(obj["publisher"]),
+		Images:      jsonImages(obj["image"]),
+		Steps:       jsonInstructions(obj["recipeInstructions"]),
+		PrepTime:    parseDuration(jsonString(obj["prepTime"])),
+		CookTime:    parseDuration(jsonString(obj["cookTime"])),
+		TotalTime:   parseDuration(jsonString(obj["totalTime"])),
+		Yield:       jsonString(obj["recipeYield"]),
+	}
+
+	ingredients := obj["recipeIngredient"]
+	if ingredients == nil {
+		// The older, deprecated name for the property.
+		ingredients = obj["ingredients"]
+	}
+	for _, ing := range jsonList(ingredients) {
+		if s := jsonString(ing); s != "" {
+			rec.Ingredients = append(rec.Ingredients, s)
+		}
+	}
+
+	if nutrition, ok := obj["nutrition"].(map[string]any); ok {
+		rec.Nutrition = nutritionLines(func(prop string) string {
+			return jsonString(nutrition[prop])
+		})
+	}
+
+	return rec, true
+}
+
+func findRecipe(v any) (map[string]any, bool) {
+	switch t := v.(type) {
+	case []any:
+		for _, e := range t {
+			if obj, ok := findRecipe(e); ok {
+				return obj, true
+			}
+		}
+	case map[string]any:
+		if isType(t["@type"], "Recipe") {
+			return t, true
+		}
+		if obj, ok := findRecipe(t["@graph"]); ok {
+			return obj, true
+		}
+		// Some sites nest the recipe as the main entity of a web page.
+		if obj, ok := findRecipe(t["mainEntity"]); ok {
+			return obj, true
+		}
+		// Failing that, look through everything else, in a stable order.
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			if k != "@graph" && k != "mainEntity" {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if obj, ok := findRecipe(t[k]); ok {
+				return obj, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func isType(v any, want string) bool {
+	for _, t := range jsonList(v) {
+		if s, ok := t.(string); ok && (s == want || strings.HasSuffix(s, "/"+want)) {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonList returns v as a list, wrapping it if it's a single value.
+func jsonList(v any) []any {
+	switch t := v.(type) {
+	case nil:
+		return nil
+	case []any:
+		return t
+	default:
+		return []any{t}
+	}
+}
+
+// jsonName returns the name of something that's either a string or an object
+// like an Organization.
+func jsonName(v any) string {
+	for _, e := range jsonList(v) {
+		switch t := e.(type) {
+		case string:
+			return cleanText(t)
+		case map[string]any:
+			if s := jsonString(t["name"]); s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+func jsonImages(v any) []string {
+	var out []string
+	for _, e := range jsonList(v) {
+		switch t := e.(type) {
+		case string:
+			out = append(out, t)
+		case map[string]any:
+			if s := jsonString(t["url"]); s != "" {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
+
+// jsonInstructions flattens recipe instructions, which can be a single
+// string, a list of strings, HowToSteps, or HowToSections containing steps.
+func jsonInstructions(v any) []string {
+	var out []string
+	for _, e := range jsonList(v) {
+		switch t := e.(type) {
+		case string:
+			// A single blob of instructions is usually one step per line.
+			for _, line := range strings.Split(t, "\n") {
+				if s := cleanText(line); s != "" {
+					out = append(out, s)
+				}
+			}
+		case map[string]any:
+			if isType(t["@type"], "HowToSection") {
+				out = append(out, jsonInstructions(t["itemListElement"])...)
+				continue
+			}
+			s := jsonString(t["text"])
+			if s == "" {
+				s = jsonString(t["name"])
+			}
+			if s != "" {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
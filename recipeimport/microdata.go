@@ -0,0 +1,236 @@
+package recipeimport
+
+import (
+	"strings"
+
+	xhtml "golang.org/x/net/html"
+)
+
+// recipeFromMicrodata looks for an element marked up as a schema.org Recipe
+// with microdata attributes.
+func recipeFromMicrodata(doc *xhtml.Node) (*schemaRecipe, bool) {
+	root := findNode(doc, func(n *xhtml.Node) bool {
+		if !hasAttr(n, "itemscope") {
+			return false
+		}
+		for _, t := range strings.Fields(attr(n, "itemtype")) {
+			if isType(t, "Recipe") {
+				return true
+			}
+		}
+		return false
+	})
+	if root == nil {
+		return nil, false
+	}
+
+	props := itemProps(root)
+	first := func(name string) string {
+		for _, n := range props[name] {
+			if s := propValue(n); s != "" {
+				return s
+			}
+		}
+		return ""
+	}
+	all := func(name string) []string {
+		var out []string
+		for _, n := range props[name] {
+			if s := propValue(n); s != "" {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+
+	rec := &schemaRecipe{
+		Name:        first("name"),
+		Description: first("description"),
+		URL:         first("url"),
+		Images:      all("image"),
+		Ingredients: all("recipeIngredient"),
+		PrepTime:    parseDuration(first("prepTime")),
+		CookTime:    parseDuration(first("cookTime")),
+		TotalTime:   parseDuration(first("totalTime")),
+		Yield:       first("recipeYield"),
+	}
+	if len(rec.Ingredients) == 0 {
+		rec.Ingredients = all("ingredients")
+	}
+	for _, n := range props["recipeInstructions"] {
+		// Instructions are often a list, with one step per list item.
+		if items := findNodes(n, func(c *xhtml.Node) bool { return c.Data == "li" }); len(items) > 0 {
+			for _, li := range items {
+				if s := cleanText(textContent(li)); s != "" {
+					rec.Steps = append(rec.Steps, s)
+				}
+			}
+			continue
+		}
+		if s := propValue(n); s != "" {
+			rec.Steps = append(rec.Steps, s)
+		}
+	}
+	for _, n := range props["publisher"] {
+		if hasAttr(n, "itemscope") {
+			sub := itemProps(n)
+			if len(sub["name"]) > 0 {
+				rec.Publisher = propValue(sub["name"][0])
+				break
+			}
+		}
+		if s := propValue(n); s != "" {
+			rec.Publisher = s
+			break
+		}
+	}
+	for _, n := range props["nutrition"] {
+		sub := itemProps(n)
+		rec.Nutrition = nutritionLines(func(prop string) string {
+			if len(sub[prop]) == 0 {
+				return ""
+			}
+			return propValue(sub[prop][0])
+		})
+		break
+	}
+
+	return rec, true
+}
+
+// itemProps collects the itemprop elements belonging to the item rooted at
+// n, without descending into nested items.
+func itemProps(n *xhtml.Node) map[string][]*xhtml.Node {
+	out := make(map[string][]*xhtml.Node)
+	var walk func(*xhtml.Node)
+	walk = func(n *xhtml.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != xhtml.ElementNode {
+				continue
+			}
+			for _, prop := range strings.Fields(attr(c, "itemprop")) {
+				out[prop] = append(out[prop], c)
+			}
+			if !hasAttr(c, "itemscope") {
+				walk(c)
+			}
+		}
+	}
+	walk(n)
+	return out
+}
+
+// propValue returns the value of a microdata property, following the rules
+// for which attribute holds the value for each element type.
+func propValue(n *xhtml.Node) string {
+	if hasAttr(n, "content") {
+		return cleanText(attr(n, "content"))
+	}
+	switch n.Data {
+	case "img", "audio", "video", "source":
+		return attr(n, "src")
+	case "a", "link":
+		return attr(n, "href")
+	case "meta":
+		return cleanText(attr(n, "content"))
+	case "time":
+		if hasAttr(n, "datetime") {
+			return attr(n, "datetime")
+		}
+	}
+	return cleanText(textContent(n))
+}
+
+type metadata struct {
+	siteName     string
+	canonicalURL string
+}
+
+func pageMetadata(doc *xhtml.Node) metadata {
+	var md metadata
+	findNodes(doc, func(n *xhtml.Node) bool {
+		switch {
+		case n.Data == "meta" && attr(n, "property") == "og:site_name" && md.siteName == "":
+			md.siteName = cleanText(attr(n, "content"))
+		case n.Data == "link" && attr(n, "rel") == "canonical" && md.canonicalURL == "":
+			md.canonicalURL = attr(n, "href")
+		}
+		return false
+	})
+	return md
+}
+
+func findNode(n *xhtml.Node, match func(*xhtml.Node) bool) *xhtml.Node {
+	if n.Type == xhtml.ElementNode && match(n) {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findNode(c, match); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func findNodes(n *xhtml.Node, match func(*xhtml.Node) bool) []*xhtml.Node {
+	var out []*xhtml.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == xhtml.ElementNode && match(c) {
+			out = append(out, c)
+		}
+		out = append(out, findNodes(c, match)...)
+	}
+	return out
+}
+
+// textContent returns the text inside n with its whitespace collapsed.
+// Inline elements are joined as-is, so "<b>1</b>/2" reads "1/2", while block
+// elements and line breaks are kept apart by a space.
+func textContent(n *xhtml.Node) string {
+	var sb strings.Builder
+	var walk func(*xhtml.Node)
+	walk = func(n *xhtml.Node) {
+		switch {
+		case n.Type == xhtml.TextNode:
+			sb.WriteString(n.Data)
+			return
+		case n.Type == xhtml.ElementNode && blockElements[n.Data]:
+			sb.WriteString(" ")
+			defer sb.WriteString(" ")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
+
+// blockElements are the elements whose text doesn't run into what's around
+// it.
+var blockElements = map[string]bool{
+	"address": true, "article": true, "blockquote": true, "br": true,
+	"dd": true, "div": true, "dl": true, "dt": true, "figcaption": true,
+	"footer": true, "h1": true, "h2": true, "h3": true, "h4": true,
+	"h5": true, "h6": true, "header": true, "hr": true, "li": true,
+	"ol": true, "p": true, "section": true, "table": true, "td": true,
+	"th": true, "tr": true, "ul": true,
+}
+
+func attr(n *xhtml.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasAttr(n *xhtml.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,239 @@
+package recipeimport
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	xhtml "golang.org/x/net/html"
+)
+
+func TestFromFile(t *testing.T) {
+	type recipe struct {
+		Name, Note, SourceName, SourceURL string
+		Photos                            []string
+		Ingredients                       []string
+		Steps                             []string
+		Nutrition                         string
+		PrepTime, CookTime                int32
+		Servings                          string
+	}
+	tests := []struct {
+		file      string
+		sourceURL string
+		want      recipe
+	}{
+		{
+			// JSON-LD in an @graph, with HowToSections, image objects and only a
+			// total time.
+			file: "jsonld_graph.html",
+			want: recipe{
+				Name:       "Weeknight Chicken Curry",
+				Note:       "A quick curry with pantry spices & coconut milk.",
+				SourceName: "Simply Dinners Kitchen",
+				SourceURL:  "https://www.simplydinners.example/chicken-curry/",
+				Photos: []string{
+					"https://www.simplydinners.example/img/curry-16x9.jpg",
+					"https://www.simplydinners.example/img/curry-1x1.jpg",
+				},
+				Ingredients: []string{
+					"1 1/2 lbs chicken thighs, cut into chunks",
+					"1 (14 oz) can coconut milk",
+					"2 tbsp curry powder",
+					"salt, to taste",
+				},
+				Steps: []string{
+					"Season the chicken with salt.",
+					"Brown the chicken in a large pot.",
+					"Simmer with the curry powder and coconut milk for 20 minutes.",
+				},
+				Nutrition: "Calories: 410 kcal\nFat: 24 g\nProtein: 32 g",
+				PrepTime:  15 * 60,
+				CookTime:  30 * 60,
+				Servings:  "4",
+			},
+		},
+		{
+			// A list of JSON-LD objects after a script without a recipe, using the
+			// old ingredients property and instructions as one string.
+			file: "jsonld_list.html",
+			want: recipe{
+				Name:        "Grandma's Banana Bread",
+				SourceName:  "homebaking.example",
+				SourceURL:   "https://homebaking.example/recipes/banana-bread",
+				Photos:      []string{"https://homebaking.example/banana-bread.jpg"},
+				Ingredients: []string{"3 ripe bananas, mashed", "1/3 cup melted butter", "1 1/2 cups flour"},
+				Steps:       []string{"Preheat the oven to 350°F.", "Mix everything together.", "Bake for about an hour."},
+				PrepTime:    10 * 60,
+				CookTime:    65 * 60,
+				Servings:    "1",
+			},
+		},
+		{
+			// The source URL we're given wins over the page's own.
+			file:      "jsonld_list.html",
+			sourceURL: "https://www.example.com/banana",
+			want: recipe{
+				Name:        "Grandma's Banana Bread",
+				SourceName:  "example.com",
+				SourceURL:   "https://www.example.com/banana",
+				Photos:      []string{"https://homebaking.example/banana-bread.jpg"},
+				Ingredients: []string{"3 ripe bananas, mashed", "1/3 cup melted butter", "1 1/2 cups flour"},
+				Steps:       []string{"Preheat the oven to 350°F.", "Mix everything together.", "Bake for about an hour."},
+				PrepTime:    10 * 60,
+				CookTime:    65 * 60,
+				Servings:    "1",
+			},
+		},
+		{
+			// Microdata, since the page's only JSON-LD isn't a recipe.
+			file: "microdata.html",
+			want: recipe{
+				Name:        "Classic Pancakes",
+				Note:        "Fluffy pancakes, ready in twenty minutes.",
+				SourceName:  "Breakfast Table Media",
+				SourceURL:   "https://breakfast.example/pancakes",
+				Photos:      []string{"https://breakfast.example/pancakes.jpg"},
+				Ingredients: []string{"1/2 cup milk", "1 cup flour", "1 egg"},
+				Steps: []string{
+					"Whisk the milk and egg.",
+					"Fold in the flour. Don't overmix.",
+					"Cook on a hot griddle until golden.",
+				},
+				Nutrition: "Calories: 90 calories\nSugar: 2 g",
+				PrepTime:  5 * 60,
+				CookTime:  15 * 60,
+				Servings:  "8 pancakes",
+			},
+		},
+	}
+	for _, test := range tests {
+		in, err := FromFile(filepath.Join("testdata", test.file), test.sourceURL)
+		if err != nil {
+			t.Errorf("FromFile(%q, %q): %v", test.file, test.sourceURL, err)
+			continue
+		}
+		got := recipe{
+			Name:       in.Name,
+			Note:       in.Note,
+			SourceName: in.SourceName,
+			SourceURL:  in.SourceUrl,
+			Photos:     in.PhotoUrls,
+			Steps:      in.PreparationSteps,
+			Nutrition:  in.NutritionalInfo,
+			PrepTime:   in.PrepTime,
+			CookTime:   in.CookTime,
+			Servings:   in.Servings,
+		}
+		for _, ing := range in.Ingredients {
+			got.Ingredients = append(got.Ingredients, ing.RawIngredient)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("FromFile(%q, %q) = %+v, want %+v", test.file, test.sourceURL, got, test.want)
+		}
+		if in.Identifier == "" || in.Timestamp == 0 || in.CreationTimestamp == 0 || in.ScaleFactor != 1 {
+			t.Errorf("FromFile(%q, %q) isn't ready to save: %+v", test.file, test.sourceURL, in)
+		}
+	}
+}
+
+func TestFromFileErrors(t *testing.T) {
+	tests := []struct {
+		file string
+		want error
+	}{
+		{file: "no_recipe.html", want: ErrNoRecipe},
+		{file: "missing.html"},
+	}
+	for _, test := range tests {
+		_, err := FromFile(filepath.Join("testdata", test.file), "")
+		if err == nil {
+			t.Errorf("FromFile(%q) succeeded, want an error", test.file)
+		} else if test.want != nil && !errors.Is(err, test.want) {
+			t.Errorf("FromFile(%q) = %v, want %v", test.file, err, test.want)
+		}
+	}
+}
+
+func TestRecipeFromJSONLD(t *testing.T) {
+	tests := []struct {
+		script string
+		ok     bool
+		name   string
+		steps  []string
+	}{
+		{script: `{"@type": "Recipe", "name": "Toast"}`, ok: true, name: "Toast"},
+		{script: `{"@type": "WebPage", "mainEntity": {"@type": "Recipe", "name": "Toast"}}`, ok: true, name: "Toast"},
+		{script: `{"@type": "ItemPage", "about": [{"@type": "Recipe", "name": "Toast"}]}`, ok: true, name: "Toast"},
+		{script: `{"@type": "Recipe", "name": ["Toast", "Bread"]}`, ok: true, name: "Toast"},
+		{
+			script: `{"@type": "Recipe", "name": "Toast", "recipeInstructions": ["Toast the bread.", {"@type": "HowToStep", "text": "Butter it."}]}`,
+			ok:     true,
+			name:   "Toast",
+			steps:  []string{"Toast the bread.", "Butter it."},
+		},
+		{script: `{"@type": "WebPage", "name": "Toast"}`},
+		{script: `not json`},
+	}
+	for _, test := range tests {
+		rec, ok := recipeFromJSONLD(test.script)
+		if ok != test.ok {
+			t.Errorf("recipeFromJSONLD(%s) ok = %t, want %t", test.script, ok, test.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if rec.Name != test.name {
+			t.Errorf("recipeFromJSONLD(%s) name = %q, want %q", test.script, rec.Name, test.name)
+		}
+		if !reflect.DeepEqual(rec.Steps, test.steps) {
+			t.Errorf("recipeFromJSONLD(%s) steps = %q, want %q", test.script, rec.Steps, test.steps)
+		}
+	}
+}
+
+func TestTextContent(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{in: "<b>1</b>/2 cup milk", want: "1/2 cup milk"},
+		{in: "1 <span>cup</span>\n  flour ", want: "1 cup flour"},
+		{in: "Stir.<br>Serve.", want: "Stir. Serve."},
+		{in: "<p>Stir.</p><p>Serve.</p>", want: "Stir. Serve."},
+		{in: "<ul><li>salt</li><li>pepper</li></ul>", want: "salt pepper"},
+	}
+	for _, test := range tests {
+		doc, err := xhtml.Parse(strings.NewReader("<div>" + test.in + "</div>"))
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", test.in, err)
+		}
+		div := findNode(doc, func(n *xhtml.Node) bool { return n.Data == "div" })
+		if got := textContent(div); got != test.want {
+			t.Errorf("textContent(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{in: "PT1H30M", want: 90 * time.Minute},
+		{in: "pt45m", want: 45 * time.Minute},
+		{in: "P1DT2H", want: 26 * time.Hour},
+		{in: "PT0.5H", want: 30 * time.Minute},
+		{in: " PT90S ", want: 90 * time.Second},
+		{in: "45 minutes"},
+		{in: ""},
+	}
+	for _, test := range tests {
+		if got := parseDuration(test.in); got != test.want {
+			t.Errorf("parseDuration(%q) = %v, want %v", test.in, got, test.want)
+		}
+	}
+}
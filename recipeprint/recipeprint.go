@@ -0,0 +1,179 @@
+// Package recipeprint renders recipes as Markdown or as a printable HTML page.
+package recipeprint
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/bcspragu/anylist/anylist"
+	"github.com/bcspragu/anylist/ingredient"
+	"github.com/bcspragu/anylist/pb"
+)
+
+// Options control how a recipe is rendered.
+type Options struct {
+	// Scale multiplies ingredient quantities and servings. Zero means use the
+	// recipe's own scale factor.
+	Scale float64
+	// Units converts ingredient quantities to the given system of
+	// measurement. NoSystem leaves them as written.
+	Units ingredient.System
+}
+
+// recipe is a recipe with scaling and unit conversion already applied, ready
+// to be rendered.
+type recipe struct {
+	Name        string
+	Note        string
+	SourceName  string
+	SourceURL   string
+	PhotoURL    string
+	Scale       float64
+	Servings    string
+	PrepTime    string
+	CookTime    string
+	Ingredients []string
+	Steps       []string
+	Nutrition   []string
+}
+
+func prepare(in *pb.PBRecipe, opts Options) *recipe {
+	scale := opts.Scale
+	if scale == 0 {
+		scale = in.ScaleFactor
+	}
+	if scale == 0 {
+		scale = 1
+	}
+
+	var ingredients []string
+	for _, ing := range in.Ingredients {
+		// Leave ingredients exactly as written unless we need to change them.
+		if scale == 1 && opts.Units == ingredient.NoSystem && ing.RawIngredient != "" {
+			ingredients = append(ingredients, ing.RawIngredient)
+			continue
+		}
+		ingredients = append(ingredients, ingredient.FromPB(ing).Scale(scale).Convert(opts.Units).String())
+	}
+
+	var photoURL string
+	if len(in.PhotoUrls) > 0 {
+		photoURL = in.PhotoUrls[0]
+	}
+
+	return &recipe{
+		Name:        in.Name,
+		Note:        in.Note,
+		SourceName:  in.SourceName,
+		SourceURL:   in.SourceUrl,
+		PhotoURL:    photoURL,
+		Scale:       scale,
+		Servings:    ingredient.ScaleQuantity(in.Servings, scale),
+		PrepTime:    anylist.FormatRecipeTime(in.PrepTime),
+		CookTime:    anylist.FormatRecipeTime(in.CookTime),
+		Ingredients: ingredients,
+		Steps:       in.PreparationSteps,
+		Nutrition:   nonEmptyLines(in.NutritionalInfo),
+	}
+}
+
+// Markdown renders the recipe as Markdown.
+func Markdown(in *pb.PBRecipe, opts Options) string {
+	r := prepare(in, opts)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", r.Name)
+
+	if r.Note != "" {
+		fmt.Fprintf(&b, "%s\n\n", r.Note)
+	}
+
+	var meta []string
+	if r.Servings != "" {
+		meta = append(meta, "**Servings:** "+r.Servings)
+	}
+	if r.PrepTime != "" {
+		meta = append(meta, "**Prep time:** "+r.PrepTime)
+	}
+	if r.CookTime != "" {
+		meta = append(meta, "**Cook time:** "+r.CookTime)
+	}
+	if r.Scale != 1 {
+		meta = append(meta, "**Scaled:** "+formatScale(r.Scale))
+	}
+	if len(meta) > 0 {
+		fmt.Fprintf(&b, "%s\n\n", strings.Join(meta, "  \n"))
+	}
+
+	if len(r.Ingredients) > 0 {
+		b.WriteString("## Ingredients\n\n")
+		for _, ing := range r.Ingredients {
+			fmt.Fprintf(&b, "- %s\n", ing)
+		}
+		b.WriteString("\n")
+	}
+
+	if len(r.Steps) > 0 {
+		b.WriteString("## Directions\n\n")
+		for i, step := range r.Steps {
+			fmt.Fprintf(&b, "%d. %s\n", i+1, step)
+		}
+		b.WriteString("\n")
+	}
+
+	if len(r.Nutrition) > 0 {
+		b.WriteString("## Nutrition\n\n")
+		for _, n := range r.Nutrition {
+			fmt.Fprintf(&b, "- %s\n", n)
+		}
+		b.WriteString("\n")
+	}
+
+	switch {
+	case r.SourceName != "" && r.SourceURL != "":
+		fmt.Fprintf(&b, "Source: [%s](%s)\n", r.SourceName, r.SourceURL)
+	case r.SourceURL != "":
+		fmt.Fprintf(&b, "Source: <%s>\n", r.SourceURL)
+	case r.SourceName != "":
+		fmt.Fprintf(&b, "Source: %s\n", r.SourceName)
+	}
+
+	return strings.TrimRight(b.String(), "\n") + "\n"
+}
+
+//go:embed print.html
+var printTemplate string
+
+var tmpl = template.Must(template.New("print").Parse(printTemplate))
+
+// HTML renders the recipe as a standalone HTML page, styled for printing.
+func HTML(w io.Writer, in *pb.PBRecipe, opts Options) error {
+	// Render to a buffer first so we don't write half a page on error.
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, prepare(in, opts)); err != nil {
+		return fmt.Errorf("failed to execute template: %w", err)
+	}
+	if _, err := buf.WriteTo(w); err != nil {
+		return fmt.Errorf("failed to write HTML: %w", err)
+	}
+	return nil
+}
+
+func formatScale(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64) + "x"
+}
+
+func nonEmptyLines(s string) []string {
+	var out []string
+	for _, l := range strings.Split(s, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			out = append(out, l)
+		}
+	}
+	return out
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bcspragu/anylist/anylist"
+	"github.com/bcspragu/anylist/ingredient"
+	"github.com/bcspragu/anylist/pb"
+	"github.com/bcspragu/anylist/recipeprint"
+	"github.com/bcspragu/anylist/store"
+)
+
+type RecipeSummary struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Icon   string `json:"icon,omitempty"`
+	Rating int    `json:"rating"`
+	// Times are in seconds, zero means unknown.
+	PrepTime int    `json:"prep_time"`
+	CookTime int    `json:"cook_time"`
+	Servings string `json:"servings,omitempty"`
+}
+
+type Recipe struct {
+	RecipeSummary
+	Note            string       `json:"note,omitempty"`
+	SourceName      string       `json:"source_name,omitempty"`
+	SourceURL       string       `json:"source_url,omitempty"`
+	Ingredients     []Ingredient `json:"ingredients"`
+	Steps           []string     `json:"steps"`
+	PhotoURLs       []string     `json:"photo_urls,omitempty"`
+	ScaleFactor     float64      `json:"scale_factor"`
+	NutritionalInfo string       `json:"nutritional_info,omitempty"`
+	CreatedAt       *time.Time   `json:"created_at,omitempty"`
+}
+
+type Ingredient struct {
+	Raw      string `json:"raw"`
+	Name     string `json:"name"`
+	Quantity string `json:"quantity,omitempty"`
+	Note     string `json:"note,omitempty"`
+}
+
+type RecipeCollection struct {
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	SortOrder   string          `json:"sort_order"`
+	RecipeCount int             `json:"recipe_count"`
+	Recipes     []RecipeSummary `json:"recipes,omitempty"`
+}
+
+// handleRecipes serves /api/recipes, which lists every recipe, and
+// /api/recipes/{id}, which returns a single recipe with its ingredients and
+// steps.
+func handleRecipes(recipeData func() *anylist.RecipeData) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, http.MethodGet) {
+			return
+		}
+		rd := recipeData()
+		id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/recipes"), "/")
+		if id == "" {
+			recipes := rd.Recipes()
+			if s := r.URL.Query().Get("sort"); s != "" {
+				order, err := anylist.ParseRecipeSortOrder(s)
+				if err != nil {
+					writeError(w, http.StatusBadRequest, err.Error())
+					return
+				}
+				anylist.SortRecipes(recipes, order)
+			}
+			writeJSON(w, http.StatusOK, toRecipeSummaries(recipes))
+			return
+		}
+
+		recipe, ok := rd.Recipe(id)
+		if !ok {
+			writeError(w, http.StatusNotFound, "recipe not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, toRecipe(recipe))
+	}
+}
+
+// handleRecipeCollections serves /api/recipe-collections, which lists the
+// user's collections (starting with the all-recipes collection), and
+// /api/recipe-collections/{id}, which includes the collection's recipes. The
+// collection's sort order can be overridden with the sort query parameter.
+func handleRecipeCollections(recipeData func() *anylist.RecipeData) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, http.MethodGet) {
+			return
+		}
+		rd := recipeData()
+		id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/recipe-collections"), "/")
+		if id == "" {
+			var cols []*pb.PBRecipeCollection
+			if all := rd.AllRecipesCollection(); all != nil {
+				cols = append(cols, all)
+			}
+			cols = append(cols, rd.Collections()...)
+
+			out := []RecipeCollection{}
+			for _, col := range cols {
+				// We already know the collection exists.
+				recipes, _ := rd.CollectionRecipes(col.Identifier)
+				rc := toRecipeCollection(col)
+				rc.RecipeCount = len(recipes)
+				out = append(out, rc)
+			}
+			writeJSON(w, http.StatusOK, out)
+			return
+		}
+
+		col, ok := rd.Collection(id)
+		if !ok {
+			writeError(w, http.StatusNotFound, "recipe collection not found")
+			return
+		}
+
+		order := pb.PBRecipeCollectionSettings_SortOrder(col.GetCollectionSettings().GetRecipesSortOrder())
+		if s := r.URL.Query().Get("sort"); s != "" {
+			o, err := anylist.ParseRecipeSortOrder(s)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			order = o
+		}
+		recipes, err := rd.CollectionRecipesSorted(id, order)
+		if err != nil {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		rc := toRecipeCollection(col)
+		rc.SortOrder = sortOrderName(order)
+		rc.RecipeCount = len(recipes)
+		rc.Recipes = toRecipeSummaries(recipes)
+		writeJSON(w, http.StatusOK, rc)
+	}
+}
+
+func toRecipeSummaries(in []*pb.PBRecipe) []RecipeSummary {
+	out := []RecipeSummary{}
+	for _, r := range in {
+		out = append(out, toRecipeSummary(r))
+	}
+	return out
+}
+
+func toRecipeSummary(r *pb.PBRecipe) RecipeSummary {
+	return RecipeSummary{
+		ID:       r.Identifier,
+		Name:     r.Name,
+		Icon:     r.Icon,
+		Rating:   int(r.Rating),
+		PrepTime: int(r.PrepTime),
+		CookTime: int(r.CookTime),
+		Servings: r.Servings,
+	}
+}
+
+func toRecipe(r *pb.PBRecipe) Recipe {
+	ingredients := []Ingredient{}
+	for _, ing := range r.Ingredients {
+		ingredients = append(ingredients, Ingredient{
+			Raw:      ing.RawIngredient,
+			Name:     ing.Name,
+			Quantity: ing.Quantity,
+			Note:     ing.Note,
+		})
+	}
+	steps := r.PreparationSteps
+	if steps == nil {
+		steps = []string{}
+	}
+	scale := r.ScaleFactor
+	if scale == 0 {
+		scale = 1
+	}
+
+	out := Recipe{
+		RecipeSummary:   toRecipeSummary(r),
+		Note:            r.Note,
+		SourceName:      r.SourceName,
+		SourceURL:       r.SourceUrl,
+		Ingredients:     ingredients,
+		Steps:           steps,
+		PhotoURLs:       r.PhotoUrls,
+		ScaleFactor:     scale,
+		NutritionalInfo: r.NutritionalInfo,
+	}
+	if r.CreationTimestamp > 0 {
+		t := fromTimestamp(r.CreationTimestamp)
+		out.CreatedAt = &t
+	}
+	return out
+}
+
+func toRecipeCollection(col *pb.PBRecipeCollection) RecipeCollection {
+	order := pb.PBRecipeCollectionSettings_SortOrder(col.GetCollectionSettings().GetRecipesSortOrder())
+	return RecipeCollection{
+		ID:        col.Identifier,
+		Name:      col.Name,
+		SortOrder: sortOrderName(order),
+	}
+}
+
+func sortOrderName(o pb.PBRecipeCollectionSettings_SortOrder) string {
+	switch o {
+	case pb.PBRecipeCollectionSettings_AlphabeticalSortOrder:
+		return "alphabetical"
+	case pb.PBRecipeCollectionSettings_RatingSortOrder:
+		return "rating"
+	case pb.PBRecipeCollectionSettings_DateCreatedSortOrder:
+		return "date_created"
+	case pb.PBRecipeCollectionSettings_PrepTimeSortOrder:
+		return "prep_time"
+	case pb.PBRecipeCollectionSettings_CookTimeSortOrder:
+		return "cook_time"
+	default:
+		return "manual"
+	}
+}
+
+// fromTimestamp converts AnyList's floating point seconds since the epoch to
+// a time.Time.
+func fromTimestamp(ts float64) time.Time {
+	sec, frac := math.Modf(ts)
+	return time.Unix(int64(sec), int64(frac*1e9)).UTC()
+}
+
+// handleAddRecipeToList serves POST /api/recipes/{id}/add-to-list, which adds
+// the recipe's ingredients to a shopping list. The target list is taken from
+// the list_id form value, falling back to the user's configured list for
+// recipe ingredients, then to defaultListID. The optional scale form value
+// scales ingredient quantities.
+func handleAddRecipeToList(c *anylist.Client, st *store.Store, undo *undoHistory, defaultListID func() string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, http.MethodPost) {
+			return
+		}
+
+		resp := st.Data()
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/recipes/"), "/add-to-list")
+		recipe, ok := anylist.NewRecipeData(resp).Recipe(id)
+		if !ok {
+			writeError(w, http.StatusNotFound, "recipe not found")
+			return
+		}
+
+		var scale float64
+		if s := r.PostFormValue("scale"); s != "" {
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil || f <= 0 {
+				writeError(w, http.StatusBadRequest, "invalid scale")
+				return
+			}
+			scale = f
+		}
+
+		listID := r.PostFormValue("list_id")
+		if listID == "" {
+			listID = resp.GetMobileAppSettingsResponse().GetListIdForRecipeIngredients()
+		}
+		if listID == "" {
+			listID = defaultListID()
+		}
+
+		// What gets added depends on what's already on the list.
+		unlock := st.LockList(listID)
+		defer unlock()
+		list, ok := listByID(st.Data().GetShoppingListsResponse().GetNewLists(), listID)
+		if !ok {
+			writeError(w, http.StatusNotFound, "list not found")
+			return
+		}
+
+		ops, err := c.AddRecipeToListOperations(list, recipe, scale)
+		if err != nil {
+			log.Printf("failed to add recipe %q to list %q: %v", id, listID, err)
+			writeError(w, http.StatusInternalServerError, "failed to add recipe to list")
+			return
+		}
+		if !sendChange(w, r, c, st, undo, listID, ops...) {
+			return
+		}
+
+		writeJSON(w, http.StatusOK, addedItems(list, ops))
+	}
+}
+
+// handlePrintRecipe serves /api/recipes/{id}/print, which renders a recipe as
+// a printable HTML page, or as Markdown with format=markdown. Quantities can
+// be scaled with the scale query parameter, and converted with units=metric
+// or units=imperial.
+func handlePrintRecipe(recipeData func() *anylist.RecipeData) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, http.MethodGet) {
+			return
+		}
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/recipes/"), "/print")
+		recipe, ok := recipeData().Recipe(id)
+		if !ok {
+			writeError(w, http.StatusNotFound, "recipe not found")
+			return
+		}
+
+		q := r.URL.Query()
+		var opts recipeprint.Options
+		if s := q.Get("scale"); s != "" {
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil || f <= 0 {
+				writeError(w, http.StatusBadRequest, "invalid scale")
+				return
+			}
+			opts.Scale = f
+		}
+		switch q.Get("units") {
+		case "":
+		case "metric":
+			opts.Units = ingredient.Metric
+		case "imperial":
+			opts.Units = ingredient.Imperial
+		default:
+			writeError(w, http.StatusBadRequest, "units must be metric or imperial")
+			return
+		}
+
+		switch q.Get("format") {
+		case "", "html":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			if err := recipeprint.HTML(w, recipe, opts); err != nil {
+				log.Printf("failed to render recipe %q: %v", id, err)
+				writeError(w, http.StatusInternalServerError, "failed to render recipe")
+			}
+		case "markdown", "md":
+			w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+			io.WriteString(w, recipeprint.Markdown(recipe, opts))
+		default:
+			writeError(w, http.StatusBadRequest, "format must be html or markdown")
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/bcspragu/anylist/anylist"
+	"github.com/bcspragu/anylist/pb"
+	"github.com/bcspragu/anylist/store"
+	"github.com/bcspragu/anylist/suggest"
+)
+
+// serverState is the store, plus the views handlers build from its data. The
+// views are rebuilt lazily when the data changes.
+type serverState struct {
+	store *store.Store
+	// listName is the -grocery_list_name flag, used to pick the default list.
+	listName string
+
+	mu      sync.Mutex
+	current *snapshot
+}
+
+// snapshot is everything handlers need from one revision of the data.
+type snapshot struct {
+	revision      uint64
+	data          *pb.PBUserDataResponse
+	defaultListID string
+	suggester     *suggest.Suggester
+	recipeData    *anylist.RecipeData
+	mealPlan      *anylist.MealPlan
+}
+
+func (s *serverState) snapshot() *snapshot {
+	data, rev := s.store.Snapshot()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current != nil && s.current.revision >= rev {
+		return s.current
+	}
+
+	snap := &snapshot{
+		revision:   rev,
+		data:       data,
+		recipeData: anylist.NewRecipeData(data),
+		mealPlan:   anylist.NewMealPlan(data),
+	}
+	if list, err := targetList(data, s.listName); err == nil {
+		snap.defaultListID = list.Identifier
+	} else if s.current != nil {
+		// The store only accepts refreshes that have the list, so this shouldn't
+		// happen, but keep pointing at the old list if it does.
+		snap.defaultListID = s.current.defaultListID
+	}
+	snap.suggester = suggest.New(data, snap.defaultListID)
+
+	s.current = snap
+	return snap
+}
@@ -0,0 +1,321 @@
+// Package store holds the server's copy of a user's AnyList data, and keeps
+// it up to date as it's changed locally and refreshed from AnyList.
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/bcspragu/anylist/pb"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// ErrListNotFound is returned when updating a list that isn't in the store.
+var ErrListNotFound = errors.New("list not found")
+
+// LoadFunc loads the latest user data from AnyList, e.g. Client.Lists.
+type LoadFunc func(ctx context.Context) (*pb.PBUserDataResponse, error)
+
+// Store holds the latest user data, and a revision number that increases every
+// time the data changes.
+//
+// The data returned from a Store is shared and must not be modified. Changes
+// are made by replacing the parts of the data that changed, so readers always
+// see a consistent snapshot.
+type Store struct {
+	load LoadFunc
+
+	mu       sync.RWMutex
+	data     *pb.PBUserDataResponse
+	revision uint64
+	// refreshing is the refresh in progress, if any.
+	refreshing *refresh
+	// pending are the local updates made while refreshing, which are applied
+	// again on top of the refreshed data, since it may not include them.
+	pending []listUpdate
+	// subs are notified whenever the data changes.
+	subs map[chan struct{}]bool
+
+	locksMu sync.Mutex
+	// listLocks are the locks for lists that are locked, or waiting to be,
+	// by LockList.
+	listLocks map[string]*listLock
+}
+
+type listLock struct {
+	mu sync.Mutex
+	// refs counts who holds or is waiting for the lock, so it can be
+	// forgotten when nobody is.
+	refs int
+}
+
+type refresh struct {
+	done chan struct{}
+	err  error
+}
+
+type listUpdate struct {
+	listID string
+	fn     func(list *pb.ShoppingList)
+}
+
+// New returns a store with data from an initial load.
+func New(ctx context.Context, load LoadFunc) (*Store, error) {
+	data, err := load(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load initial data: %w", err)
+	}
+	return &Store{
+		load:      load,
+		data:      data,
+		revision:  1,
+		subs:      make(map[chan struct{}]bool),
+		listLocks: make(map[string]*listLock),
+	}, nil
+}
+
+// LockList locks a list for a change that takes more than a single update,
+// like checking an item's version, sending the change to AnyList, and then
+// applying it. It returns a function that unlocks the list. Readers and
+// refreshes aren't blocked.
+func (s *Store) LockList(listID string) func() {
+	s.locksMu.Lock()
+	l, ok := s.listLocks[listID]
+	if !ok {
+		l = &listLock{}
+		s.listLocks[listID] = l
+	}
+	l.refs++
+	s.locksMu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+
+		s.locksMu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(s.listLocks, listID)
+		}
+		s.locksMu.Unlock()
+	}
+}
+
+// Subscribe returns a channel that receives a value whenever the data changes,
+// and a function to call to stop receiving them. Changes that happen while the
+// subscriber is busy are combined into a single notification, so subscribers
+// should compare the latest Snapshot with the last one they saw.
+func (s *Store) Subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	s.mu.Lock()
+	s.subs[ch] = true
+	s.mu.Unlock()
+
+	return ch, func() {
+		s.mu.Lock()
+		delete(s.subs, ch)
+		s.mu.Unlock()
+	}
+}
+
+// Subscribers returns how many subscribers there are.
+func (s *Store) Subscribers() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.subs)
+}
+
+// notify tells subscribers the data has changed. s.mu must be held.
+func (s *Store) notify() {
+	for ch := range s.subs {
+		select {
+		case ch <- struct{}{}:
+		default:
+			// They already have a notification they haven't gotten to yet.
+		}
+	}
+}
+
+// Snapshot returns the current data and its revision.
+func (s *Store) Snapshot() (*pb.PBUserDataResponse, uint64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.data, s.revision
+}
+
+// Data returns the current data.
+func (s *Store) Data() *pb.PBUserDataResponse {
+	data, _ := s.Snapshot()
+	return data
+}
+
+// Revision returns the revision of the current data.
+func (s *Store) Revision() uint64 {
+	_, rev := s.Snapshot()
+	return rev
+}
+
+// Refresh reloads the data from AnyList. If a refresh is already in progress,
+// Refresh waits for it and returns its result instead of starting another one.
+//
+// Local updates made while the refresh is loading are applied again on top of
+// the loaded data, since it may not include them yet.
+func (s *Store) Refresh(ctx context.Context) error {
+	s.mu.Lock()
+	if r := s.refreshing; r != nil {
+		s.mu.Unlock()
+		select {
+		case <-r.done:
+			return r.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	r := &refresh{done: make(chan struct{})}
+	s.refreshing = r
+	s.mu.Unlock()
+
+	data, err := s.load(ctx)
+	if err != nil {
+		err = fmt.Errorf("failed to load data: %w", err)
+	}
+
+	s.mu.Lock()
+	if err == nil {
+		for _, u := range s.pending {
+			if updated, uerr := updateList(data, u.listID, u.fn); uerr == nil {
+				data = updated
+			}
+			// Otherwise, the list was deleted in the meantime.
+		}
+		s.data = data
+		s.revision++
+		s.notify()
+	}
+	s.refreshing = nil
+	s.pending = nil
+	s.mu.Unlock()
+
+	r.err = err
+	close(r.done)
+	return err
+}
+
+// UpdateList applies fn to a copy of the given list, and replaces the list
+// with it. It returns the new revision. If a refresh is in progress, fn is
+// applied again to the refreshed data, so it must only depend on its argument,
+// e.g. setting an item's field rather than toggling it.
+func (s *Store) UpdateList(listID string, fn func(list *pb.ShoppingList)) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := updateList(s.data, listID, fn)
+	if err != nil {
+		return 0, err
+	}
+	if s.refreshing != nil {
+		s.pending = append(s.pending, listUpdate{listID: listID, fn: fn})
+	}
+
+	s.data = data
+	s.revision++
+	s.notify()
+	return s.revision, nil
+}
+
+// updateList returns a copy of data with fn applied to a copy of the given
+// list. Everything else is shared with data.
+func updateList(data *pb.PBUserDataResponse, listID string, fn func(list *pb.ShoppingList)) (*pb.PBUserDataResponse, error) {
+	lists := data.GetShoppingListsResponse().GetNewLists()
+	idx := -1
+	for i, l := range lists {
+		if l.Identifier == listID {
+			idx = i
+			break
+		}
+	}
+	if idx == -1 {
+		return nil, ErrListNotFound
+	}
+
+	list := proto.Clone(lists[idx]).(*pb.ShoppingList)
+	fn(list)
+
+	newLists := make([]*pb.ShoppingList, len(lists))
+	copy(newLists, lists)
+	newLists[idx] = list
+
+	slr := shallowCopy(data.ShoppingListsResponse).(*pb.ShoppingListsResponse)
+	slr.NewLists = newLists
+	out := shallowCopy(data).(*pb.PBUserDataResponse)
+	out.ShoppingListsResponse = slr
+	return out, nil
+}
+
+// AddItems adds items to a list, or replaces them if they're already on it.
+func (s *Store) AddItems(listID string, items ...*pb.ListItem) (uint64, error) {
+	return s.UpdateList(listID, func(list *pb.ShoppingList) {
+		for _, item := range items {
+			item = proto.Clone(item).(*pb.ListItem)
+			if i := itemIndex(list, item.Identifier); i != -1 {
+				list.Items[i] = item
+			} else {
+				list.Items = append(list.Items, item)
+			}
+		}
+	})
+}
+
+// UpdateItem applies fn to an item on a list. It returns false if the item
+// isn't on the list.
+func (s *Store) UpdateItem(listID, itemID string, fn func(item *pb.ListItem)) (uint64, bool, error) {
+	found := false
+	rev, err := s.UpdateList(listID, func(list *pb.ShoppingList) {
+		if i := itemIndex(list, itemID); i != -1 {
+			fn(list.Items[i])
+			found = true
+		}
+	})
+	return rev, found, err
+}
+
+// RemoveItems removes items from a list by their identifiers.
+func (s *Store) RemoveItems(listID string, itemIDs ...string) (uint64, error) {
+	remove := make(map[string]bool)
+	for _, id := range itemIDs {
+		remove[id] = true
+	}
+	return s.UpdateList(listID, func(list *pb.ShoppingList) {
+		var items []*pb.ListItem
+		for _, item := range list.Items {
+			if !remove[item.Identifier] {
+				items = append(items, item)
+			}
+		}
+		list.Items = items
+	})
+}
+
+func itemIndex(list *pb.ShoppingList, itemID string) int {
+	for i, item := range list.Items {
+		if item.Identifier == itemID {
+			return i
+		}
+	}
+	return -1
+}
+
+// shallowCopy returns a new message with the same fields as m, sharing any
+// nested messages and lists with it.
+func shallowCopy(m proto.Message) proto.Message {
+	src := m.ProtoReflect()
+	dst := src.New()
+	src.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		dst.Set(fd, v)
+		return true
+	})
+	return dst.Interface()
+}
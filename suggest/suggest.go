@@ -0,0 +1,270 @@
+// Package suggest ranks item name suggestions for the "add item" box, using
+// the autocomplete sources AnyList itself uses: a list's favorites, its recent
+// items, and the account-wide set of previously categorized items.
+package suggest
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/bcspragu/anylist/anylist"
+	"github.com/bcspragu/anylist/pb"
+)
+
+// Suggestion is a single autocomplete result.
+type Suggestion struct {
+	Name string `json:"name"`
+	// Category is the display name of the category the item would likely be
+	// filed under, if we know it.
+	Category string `json:"category,omitempty"`
+}
+
+// source identifies where a candidate came from, which affects how it's
+// ranked.
+type source int
+
+const (
+	sourceCategorized source = iota
+	sourceRecent
+	sourceFavorite
+)
+
+// Weights for each source, favorites win ties over recents, which win over
+// the generic categorized items.
+var sourceWeight = map[source]int{
+	sourceCategorized: 1,
+	sourceRecent:      2,
+	sourceFavorite:    3,
+}
+
+type candidate struct {
+	name     string
+	lower    string
+	category string
+	source   source
+	// rank is the position of the candidate within its source, lower is
+	// better (e.g. more recently used).
+	rank int
+}
+
+// Suggester holds the candidates for a single list. It's immutable once
+// built, so it's safe for concurrent use.
+type Suggester struct {
+	candidates []*candidate
+	// onList holds the lowercased names of unchecked items already on the
+	// list, which we don't bother suggesting.
+	onList map[string]bool
+}
+
+// New builds a Suggester for the given list from a full user data response,
+// respecting the list's autocomplete settings.
+func New(resp *pb.PBUserDataResponse, listID string) *Suggester {
+	s := &Suggester{onList: make(map[string]bool)}
+
+	favoritesEnabled, recentsEnabled := true, true
+	if ls, ok := anylist.ListSettings(resp, listID); ok && autocompleteSet(ls) {
+		favoritesEnabled = ls.FavoritesAutocompleteEnabled
+		recentsEnabled = ls.RecentItemsAutocompleteEnabled
+	}
+
+	categories := anylist.ListCategoryNames(resp, listID)
+	rules := categorizationRules(resp, listID, categories)
+
+	if list := shoppingList(resp, listID); list != nil {
+		for _, item := range list.Items {
+			if !item.Checked {
+				s.onList[strings.ToLower(item.Name)] = true
+			}
+		}
+	}
+
+	// Candidates are deduplicated by lowercased name, keeping whichever source
+	// ranks highest.
+	byName := make(map[string]*candidate)
+	add := func(item *pb.ListItem, src source, rank int) {
+		name := strings.TrimSpace(item.Name)
+		if name == "" {
+			return
+		}
+		lower := strings.ToLower(name)
+		cat := rules[lower]
+		if cat == "" {
+			cat = anylist.ItemCategory(item, categories)
+		}
+		if cat == "" {
+			// Fall back to the item's own category, even if the list doesn't
+			// know it by name.
+			cat = item.Category
+		}
+		if existing, ok := byName[lower]; ok {
+			if existing.category == "" {
+				existing.category = cat
+			}
+			if sourceWeight[src] <= sourceWeight[existing.source] {
+				return
+			}
+			existing.source, existing.rank = src, rank
+			return
+		}
+		c := &candidate{
+			name:     name,
+			lower:    lower,
+			category: cat,
+			source:   src,
+			rank:     rank,
+		}
+		byName[lower] = c
+		s.candidates = append(s.candidates, c)
+	}
+
+	if sl := resp.GetStarterListsResponse(); sl != nil {
+		if favoritesEnabled {
+			for _, l := range starterLists(sl.FavoriteItemListsResponse, listID) {
+				for i, item := range l.Items {
+					add(item, sourceFavorite, i)
+				}
+			}
+		}
+		if recentsEnabled {
+			for _, l := range starterLists(sl.RecentItemListsResponse, listID) {
+				for i, item := range l.Items {
+					add(item, sourceRecent, i)
+				}
+			}
+		}
+	}
+
+	for i, item := range resp.GetCategorizedItemsResponse().GetCategorizedItems() {
+		add(item, sourceCategorized, i)
+	}
+
+	return s
+}
+
+// Suggest returns up to n suggestions for the query q, best first.
+func (s *Suggester) Suggest(q string, n int) []Suggestion {
+	q = strings.ToLower(strings.TrimSpace(q))
+	if q == "" || n <= 0 {
+		return []Suggestion{}
+	}
+
+	type scored struct {
+		c     *candidate
+		match int
+	}
+	var matches []scored
+	for _, c := range s.candidates {
+		if s.onList[c.lower] {
+			continue
+		}
+		m := matchScore(c.lower, q)
+		if m == 0 {
+			continue
+		}
+		matches = append(matches, scored{c: c, match: m})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.match != b.match {
+			return a.match > b.match
+		}
+		if wa, wb := sourceWeight[a.c.source], sourceWeight[b.c.source]; wa != wb {
+			return wa > wb
+		}
+		if a.c.rank != b.c.rank {
+			return a.c.rank < b.c.rank
+		}
+		return a.c.lower < b.c.lower
+	})
+
+	if len(matches) > n {
+		matches = matches[:n]
+	}
+	out := make([]Suggestion, 0, len(matches))
+	for _, m := range matches {
+		out = append(out, Suggestion{Name: m.c.name, Category: m.c.category})
+	}
+	return out
+}
+
+// matchScore returns how well name matches the query, or zero if it doesn't
+// match at all. Both are expected to already be lowercased.
+func matchScore(name, q string) int {
+	switch {
+	case name == q:
+		return 4
+	case strings.HasPrefix(name, q):
+		return 3
+	case hasWordPrefix(name, q):
+		return 2
+	case strings.Contains(name, q):
+		return 1
+	default:
+		return 0
+	}
+}
+
+func hasWordPrefix(name, q string) bool {
+	for _, w := range strings.Fields(name) {
+		if strings.HasPrefix(w, q) {
+			return true
+		}
+	}
+	return false
+}
+
+// autocompleteSet reports whether a list's settings say anything about
+// autocomplete. Our generated messages don't track whether a field was set, so
+// settings that leave the autocomplete flags out look like every source was
+// turned off. AnyList enables them by default, so we treat settings with every
+// flag off as not having set them.
+func autocompleteSet(ls *pb.PBListSettings) bool {
+	return ls.FavoritesAutocompleteEnabled ||
+		ls.RecentItemsAutocompleteEnabled ||
+		ls.GenericGroceryAutocompleteEnabled
+}
+
+func shoppingList(resp *pb.PBUserDataResponse, listID string) *pb.ShoppingList {
+	for _, l := range resp.GetShoppingListsResponse().GetNewLists() {
+		if l.Identifier == listID {
+			return l
+		}
+	}
+	return nil
+}
+
+// starterLists returns the starter lists (e.g. favorites or recents) that
+// apply to the given list. Lists that aren't tied to a specific list apply to
+// every list.
+func starterLists(resp *pb.StarterListBatchResponse, listID string) []*pb.StarterList {
+	var out []*pb.StarterList
+	for _, r := range resp.GetListResponses() {
+		l := r.GetStarterList()
+		if l == nil {
+			continue
+		}
+		if l.ListId != "" && l.ListId != listID {
+			continue
+		}
+		out = append(out, l)
+	}
+	return out
+}
+
+// categorizationRules maps lowercased item names to category display names
+// using the list's categorization rules.
+func categorizationRules(resp *pb.PBUserDataResponse, listID string, categories map[string]string) map[string]string {
+	out := make(map[string]string)
+	for _, lr := range resp.GetShoppingListsResponse().GetListResponses() {
+		if lr.ListId != listID {
+			continue
+		}
+		for _, rule := range lr.CategorizationRules {
+			if name, ok := categories[rule.CategoryId]; ok {
+				out[strings.ToLower(rule.ItemName)] = name
+			}
+		}
+	}
+	return out
+}
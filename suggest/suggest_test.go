@@ -0,0 +1,145 @@
+package suggest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bcspragu/anylist/pb"
+)
+
+func items(names ...string) []*pb.ListItem {
+	var out []*pb.ListItem
+	for _, n := range names {
+		out = append(out, &pb.ListItem{Name: n})
+	}
+	return out
+}
+
+func starter(listID string, names ...string) *pb.StarterListBatchResponse {
+	return &pb.StarterListBatchResponse{
+		ListResponses: []*pb.StarterListResponse{
+			{StarterList: &pb.StarterList{ListId: listID, Items: items(names...)}},
+		},
+	}
+}
+
+func userData(favorites, recents, categorized []string, onList []*pb.ListItem, settings *pb.PBListSettings) *pb.PBUserDataResponse {
+	resp := &pb.PBUserDataResponse{
+		ShoppingListsResponse: &pb.ShoppingListsResponse{
+			NewLists: []*pb.ShoppingList{{Identifier: "list", Items: onList}},
+		},
+		StarterListsResponse: &pb.StarterListsResponseV2{
+			FavoriteItemListsResponse: starter("list", favorites...),
+			RecentItemListsResponse:   starter("", recents...),
+		},
+		CategorizedItemsResponse: &pb.PBCategorizedItemsList{CategorizedItems: items(categorized...)},
+	}
+	if settings != nil {
+		resp.ListSettingsResponse = &pb.PBListSettingsList{Settings: []*pb.PBListSettings{settings}}
+	}
+	return resp
+}
+
+func names(ss []Suggestion) []string {
+	out := []string{}
+	for _, s := range ss {
+		out = append(out, s.Name)
+	}
+	return out
+}
+
+func TestSuggest(t *testing.T) {
+	tests := []struct {
+		name        string
+		favorites   []string
+		recents     []string
+		categorized []string
+		onList      []*pb.ListItem
+		settings    *pb.PBListSettings
+		q           string
+		n           int
+		want        []string
+	}{
+		{
+			name:        "match score comes first",
+			categorized: []string{"Skim milk", "Milk chocolate", "Milk", "Buttermilk"},
+			q:           "milk",
+			n:           10,
+			// Exact, then prefix, then word prefix, then substring.
+			want: []string{"Milk", "Milk chocolate", "Skim milk", "Buttermilk"},
+		},
+		{
+			name:        "then source weight",
+			favorites:   []string{"Milk chocolate"},
+			recents:     []string{"Milk powder"},
+			categorized: []string{"Milk bread"},
+			q:           "milk",
+			n:           10,
+			want:        []string{"Milk chocolate", "Milk powder", "Milk bread"},
+		},
+		{
+			name:    "then rank within the source",
+			recents: []string{"Eggplant", "Eggs", "Egg noodles"},
+			q:       "egg",
+			n:       10,
+			want:    []string{"Eggplant", "Eggs", "Egg noodles"},
+		},
+		{
+			name:        "duplicates keep the best source",
+			favorites:   []string{"Apples"},
+			recents:     []string{"apples", "Apple juice"},
+			categorized: []string{"APPLES", "Applesauce"},
+			q:           "apple",
+			n:           10,
+			want:        []string{"Apples", "Apple juice", "Applesauce"},
+		},
+		{
+			name:        "unchecked items on the list are skipped",
+			categorized: []string{"Bread", "Breadcrumbs", "Bran"},
+			onList:      []*pb.ListItem{{Name: "bread"}, {Name: "Breadcrumbs", Checked: true}},
+			q:           "br",
+			n:           10,
+			want:        []string{"Breadcrumbs", "Bran"},
+		},
+		{
+			name:        "limit",
+			categorized: []string{"Tea", "Teriyaki sauce", "Tempeh"},
+			q:           "te",
+			n:           2,
+			want:        []string{"Tea", "Teriyaki sauce"},
+		},
+		{
+			name:        "disabled sources are skipped",
+			favorites:   []string{"Rice"},
+			recents:     []string{"Rice noodles"},
+			categorized: []string{"Rice vinegar"},
+			settings:    &pb.PBListSettings{ListId: "list", GenericGroceryAutocompleteEnabled: true, RecentItemsAutocompleteEnabled: true},
+			q:           "rice",
+			n:           10,
+			want:        []string{"Rice noodles", "Rice vinegar"},
+		},
+		{
+			name:        "settings without autocomplete flags leave sources enabled",
+			favorites:   []string{"Rice"},
+			recents:     []string{"Rice noodles"},
+			categorized: []string{"Rice vinegar"},
+			settings:    &pb.PBListSettings{ListId: "list", ShouldHideCategories: true},
+			q:           "rice",
+			n:           10,
+			want:        []string{"Rice", "Rice noodles", "Rice vinegar"},
+		},
+		{
+			name:        "empty query",
+			categorized: []string{"Rice"},
+			q:           " ",
+			n:           10,
+			want:        []string{},
+		},
+	}
+	for _, test := range tests {
+		s := New(userData(test.favorites, test.recents, test.categorized, test.onList, test.settings), "list")
+		if got := names(s.Suggest(test.q, test.n)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: Suggest(%q, %d) = %q, want %q", test.name, test.q, test.n, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bcspragu/anylist/anylist"
+	"github.com/bcspragu/anylist/pb"
+)
+
+// Theme is the look of a list, as chosen in the phone app. Colors are CSS hex
+// colors, e.g. "#4A8FD9".
+type Theme struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Font        string `json:"font,omitempty"`
+	BannerColor string `json:"banner_color"`
+	// BannerTextColor is black or white, whichever reads better on the
+	// banner. Themes don't have a color for it.
+	BannerTextColor    string `json:"banner_text_color,omitempty"`
+	NavigationBarColor string `json:"navigation_bar_color"`
+	BackgroundColor    string `json:"background_color"`
+	TableColor         string `json:"table_color"`
+	CellColor          string `json:"cell_color"`
+	SeparatorColor     string `json:"separator_color"`
+	SelectionColor     string `json:"selection_color"`
+	ControlColor       string `json:"control_color"`
+	ItemNameColor      string `json:"item_name_color"`
+	ItemDetailsColor   string `json:"item_details_color"`
+	BackgroundTexture  string `json:"background_texture,omitempty"`
+	CellTexture        string `json:"cell_texture,omitempty"`
+	TableTexture       string `json:"table_texture,omitempty"`
+	BackgroundImage    string `json:"background_image,omitempty"`
+}
+
+func toTheme(in *pb.PBListTheme) *Theme {
+	return &Theme{
+		ID:                 in.Identifier,
+		Name:               in.Name,
+		Font:               in.FontName,
+		BannerColor:        cssColor(in.BannerHexColor),
+		BannerTextColor:    textColorOn(in.BannerHexColor),
+		NavigationBarColor: cssColor(in.NavigationBarHexColor),
+		BackgroundColor:    cssColor(in.BackgroundHexColor),
+		TableColor:         cssColor(in.TableHexColor),
+		CellColor:          cssColor(in.CellHexColor),
+		SeparatorColor:     cssColor(in.SeparatorHexColor),
+		SelectionColor:     cssColor(in.SelectionHexColor),
+		ControlColor:       cssColor(in.ControlHexColor),
+		ItemNameColor:      cssColor(in.ItemNameHexColor),
+		ItemDetailsColor:   cssColor(in.ItemDetailsHexColor),
+		BackgroundTexture:  in.BackgroundTexture,
+		CellTexture:        in.CellTexture,
+		TableTexture:       in.TableTexture,
+		BackgroundImage:    in.BackgroundImage,
+	}
+}
+
+// cssColor turns one of AnyList's hex colors, which usually don't have a
+// leading '#', into a CSS color. Anything that isn't a hex color is dropped.
+func cssColor(hex string) string {
+	hex = strings.TrimPrefix(hex, "#")
+	switch len(hex) {
+	case 3, 6, 8:
+	default:
+		return ""
+	}
+	for _, r := range hex {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return ""
+		}
+	}
+	return "#" + hex
+}
+
+// textColorOn returns black or white, whichever is easier to read on one of
+// AnyList's hex colors. It's empty if hex isn't a six digit hex color.
+func textColorOn(hex string) string {
+	hex = strings.TrimPrefix(hex, "#")
+	if len(hex) != 6 {
+		return ""
+	}
+	rgb, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return ""
+	}
+	r, g, b := rgb>>16, rgb>>8&0xFF, rgb&0xFF
+	// Perceived brightness, per https://www.w3.org/TR/AERT/#color-contrast
+	if (r*299+g*587+b*114)/1000 > 150 {
+		return "#000000"
+	}
+	return "#FFFFFF"
+}
+
+// listTheme returns the effective theme of the given list.
+func listTheme(in *pb.PBUserDataResponse, listID string) *Theme {
+	settings, _ := anylist.ListSettings(in, listID)
+	return toTheme(anylist.ListTheme(settings))
+}
+
+// handleListThemeCSS serves a list's theme as CSS custom properties at
+// /api/lists/{id}/theme.css, so pages can style themselves with e.g.
+// var(--list-banner-color).
+func handleListThemeCSS(userData func() *pb.PBUserDataResponse) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, http.MethodGet) {
+			return
+		}
+		listID := strings.TrimPrefix(r.URL.Path, "/api/lists/")
+		listID = strings.TrimSuffix(listID, "/theme.css")
+
+		data := userData()
+		if _, ok := listByID(data.ShoppingListsResponse.NewLists, listID); !ok {
+			writeError(w, http.StatusNotFound, "list not found")
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/css; charset=utf-8")
+		writeThemeCSS(w, listTheme(data, listID))
+	}
+}
+
+func writeThemeCSS(w io.Writer, t *Theme) {
+	props := []struct {
+		name, value string
+	}{
+		{"banner-color", t.BannerColor},
+		{"banner-text-color", t.BannerTextColor},
+		{"navigation-bar-color", t.NavigationBarColor},
+		{"background-color", t.BackgroundColor},
+		{"table-color", t.TableColor},
+		{"cell-color", t.CellColor},
+		{"separator-color", t.SeparatorColor},
+		{"selection-color", t.SelectionColor},
+		{"control-color", t.ControlColor},
+		{"item-name-color", t.ItemNameColor},
+		{"item-details-color", t.ItemDetailsColor},
+	}
+	fmt.Fprintln(w, ":root {")
+	for _, p := range props {
+		if p.value == "" {
+			continue
+		}
+		fmt.Fprintf(w, "\t--list-%s: %s;\n", p.name, p.value)
+	}
+	if t.Font != "" {
+		fmt.Fprintf(w, "\t--list-font: %q;\n", t.Font)
+	}
+	fmt.Fprintln(w, "}")
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bcspragu/anylist/anylist"
+	"github.com/bcspragu/anylist/pb"
+	"github.com/bcspragu/anylist/store"
+)
+
+const (
+	// maxUndo is how many changes each session can undo.
+	maxUndo = 20
+	// sessionTTL is how long we remember the changes of a session that hasn't
+	// made any new ones.
+	sessionTTL = 24 * time.Hour
+	// sessionCookie identifies a browser's session, so it can undo its own
+	// changes.
+	sessionCookie = "anylist_session"
+)
+
+// undoHistory keeps each session's recent changes to lists, so they can be
+// undone.
+type undoHistory struct {
+	mu       sync.Mutex
+	sessions map[string]*undoSession
+}
+
+type undoSession struct {
+	lastUsed time.Time
+	// changes are the batches of operations the session made, oldest first.
+	// Each batch is undone together.
+	changes [][]*pb.PBListOperation
+}
+
+func newUndoHistory() *undoHistory {
+	return &undoHistory{sessions: make(map[string]*undoSession)}
+}
+
+// record remembers a change made by the request's session, starting a new
+// session if it doesn't have one. It must be called before writing the
+// response, since it may set a cookie.
+func (h *undoHistory) record(w http.ResponseWriter, r *http.Request, ops ...*pb.PBListOperation) {
+	if len(ops) == 0 {
+		return
+	}
+	id, err := sessionID(w, r)
+	if err != nil {
+		log.Printf("failed to start session, change won't be undoable: %v", err)
+		return
+	}
+
+	h.push(id, ops)
+}
+
+// push adds a change to the end of a session's history.
+func (h *undoHistory) push(id string, ops []*pb.PBListOperation) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.prune()
+
+	s, ok := h.sessions[id]
+	if !ok {
+		s = &undoSession{}
+		h.sessions[id] = s
+	}
+	s.lastUsed = time.Now()
+	s.changes = append(s.changes, ops)
+	if len(s.changes) > maxUndo {
+		s.changes = s.changes[len(s.changes)-maxUndo:]
+	}
+}
+
+// pop removes and returns the session's most recent change.
+func (h *undoHistory) pop(id string) ([]*pb.PBListOperation, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.sessions[id]
+	if !ok || len(s.changes) == 0 {
+		return nil, false
+	}
+	ops := s.changes[len(s.changes)-1]
+	s.changes = s.changes[:len(s.changes)-1]
+	return ops, true
+}
+
+// prune forgets sessions that haven't been used in a while. h.mu must be held.
+func (h *undoHistory) prune() {
+	for id, s := range h.sessions {
+		if time.Since(s.lastUsed) > sessionTTL {
+			delete(h.sessions, id)
+		}
+	}
+}
+
+// sessionID returns the request's session ID from its cookie, or sets a
+// cookie with a new one.
+func sessionID(w http.ResponseWriter, r *http.Request) (string, error) {
+	if c, err := r.Cookie(sessionCookie); err == nil && c.Value != "" {
+		return c.Value, nil
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(b)
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    id,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return id, nil
+}
+
+// handleUndo serves /api/undo, which undoes the most recent change made to a
+// list by the requester's session, and returns the list as it is after the
+// undo. If anyone has changed the same items since, the change isn't undone.
+func handleUndo(c *anylist.Client, st *store.Store, undo *undoHistory) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, http.MethodPost) {
+			return
+		}
+		cookie, err := r.Cookie(sessionCookie)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "nothing to undo")
+			return
+		}
+		ops, ok := undo.pop(cookie.Value)
+		if !ok {
+			writeError(w, http.StatusNotFound, "nothing to undo")
+			return
+		}
+		listID := ops[0].ListId
+
+		// Make sure nobody has changed what we're about to undo, and that they
+		// can't through this server until we're done. Changes made elsewhere
+		// that we haven't seen yet are caught by AnyList, since the undo is sent
+		// with the values it expects to replace.
+		unlock := st.LockList(listID)
+		defer unlock()
+		list, ok := listByID(st.Data().GetShoppingListsResponse().GetNewLists(), listID)
+		if !ok {
+			writeError(w, http.StatusNotFound, "list not found")
+			return
+		}
+		for _, op := range ops {
+			if !anylist.ListOperationCurrent(list, op) {
+				writeError(w, http.StatusConflict, "list was changed since, so the change can't be undone")
+				return
+			}
+		}
+
+		// Undo the operations in the reverse order they were made.
+		var inverse []*pb.PBListOperation
+		for i := len(ops) - 1; i >= 0; i-- {
+			inv, err := anylist.InvertListOperation(ops[i])
+			if errors.Is(err, anylist.ErrNotInvertible) {
+				log.Printf("failed to undo change: %v", err)
+				writeError(w, http.StatusConflict, "change can't be undone")
+				return
+			} else if err != nil {
+				log.Printf("failed to invert operation: %v", err)
+				writeError(w, http.StatusInternalServerError, "failed to undo change")
+				return
+			}
+			inverse = append(inverse, c.NewOperation(inv))
+		}
+
+		if err := c.SendListOperations(r.Context(), inverse...); errors.Is(err, anylist.ErrNotProcessed) {
+			log.Printf("AnyList rejected undo operations: %v", err)
+			writeError(w, http.StatusConflict, "list was changed since, so the change can't be undone")
+			return
+		} else if err != nil {
+			log.Printf("failed to send undo operations: %v", err)
+			// Put the change back, so the undo can be retried.
+			undo.push(cookie.Value, ops)
+			writeError(w, http.StatusBadGateway, "failed to undo change")
+			return
+		}
+		applyOperationsLocally(st, listID, inverse)
+
+		data := st.Data()
+		updated, ok := listByID(data.GetShoppingListsResponse().GetNewLists(), listID)
+		if !ok {
+			writeError(w, http.StatusNotFound, "list not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, toList(data, updated))
+	}
+}
+
+// applyOperationsLocally applies operations that were just sent to AnyList to
+// the store's copy of a list.
+func applyOperationsLocally(st *store.Store, listID string, ops []*pb.PBListOperation) {
+	_, err := st.UpdateList(listID, func(list *pb.ShoppingList) {
+		for _, op := range ops {
+			if err := anylist.ApplyListOperation(list, op); err != nil {
+				log.Printf("failed to apply operation to list %q locally: %v", listID, err)
+			}
+		}
+	})
+	if err != nil {
+		log.Printf("failed to update list %q locally: %v", listID, err)
+	}
+}